@@ -0,0 +1,155 @@
+package loggo
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Formats of the access log messages
+const (
+	// Apache common log format
+	AccessLogCommon = iota
+	// Apache combined log format
+	AccessLogCombined
+	// Short content with the request information stored in Message.Fields
+	AccessLogStructured
+)
+
+const clfDateFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogOptions configures the access log handler
+type AccessLogOptions struct {
+	// AccessLogCommon, AccessLogCombined or AccessLogStructured
+	Format int
+	// Requests for these paths are not logged, e.g. /healthz
+	SkipPaths []string
+}
+
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", w.ResponseWriter)
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// AccessLogLevel returns the level used to log a response with the given status:
+// Error for 5xx, Warning for 4xx and Info otherwise
+func AccessLogLevel(status int) Level {
+	switch {
+	case status >= 500:
+		return Error
+	case status >= 400:
+		return Warning
+	default:
+		return Info
+	}
+}
+
+// AccessLogHandler returns a handler logging every request served by next.
+// The method, path, status, response size, duration, remote address and
+// user agent of the request are always stored in Message.Fields.
+func (l *Logger) AccessLogHandler(next http.Handler, opts AccessLogOptions) http.Handler {
+	skip := make(map[string]bool, len(opts.SkipPaths))
+	for _, path := range opts.SkipPaths {
+		skip[path] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if skip[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := l.config().nowFunc()
+		writer := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(writer, r)
+		if writer.status == 0 {
+			writer.status = http.StatusOK
+		}
+		l.logAccess(r, writer, start, opts.Format)
+	})
+}
+
+// logAccess logs the request. The caller information is the one
+// of the middleware, which is the only logger function to skip
+func (l *Logger) logAccess(r *http.Request, w *accessLogWriter, start time.Time, format int) {
+	level := AccessLogLevel(w.status)
+	if !l.Enabled(level) {
+		return
+	}
+	var content string
+	switch format {
+	case AccessLogCommon:
+		content = commonLogLine(r, w, start)
+	case AccessLogCombined:
+		content = fmt.Sprintf("%s %q %q", commonLogLine(r, w, start), r.Referer(), r.UserAgent())
+	default:
+		content = fmt.Sprintf("%s %s %d", r.Method, r.URL.RequestURI(), w.status)
+	}
+	msg := l.makeMessage(r.Context(), level, "", content, nil, 1)
+	if msg.Fields == nil {
+		msg.Fields = make(map[string]interface{}, 7)
+	}
+	msg.Fields["method"] = r.Method
+	msg.Fields["path"] = r.URL.Path
+	msg.Fields["status"] = w.status
+	msg.Fields["bytes"] = w.bytes
+	msg.Fields["duration"] = msg.Time.Sub(start)
+	msg.Fields["remote_addr"] = r.RemoteAddr
+	msg.Fields["user_agent"] = r.UserAgent()
+	l.outputLog(msg)
+}
+
+func commonLogLine(r *http.Request, w *accessLogWriter, start time.Time) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	user := "-"
+	if r.URL.User != nil && r.URL.User.Username() != "" {
+		user = r.URL.User.Username()
+	} else if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+	size := "-"
+	if w.bytes > 0 {
+		size = fmt.Sprint(w.bytes)
+	}
+	request := strings.Join([]string{r.Method, r.URL.RequestURI(), r.Proto}, " ")
+	return fmt.Sprintf("%s - %s [%s] %q %d %s", host, user, start.Format(clfDateFormat), request, w.status, size)
+}
@@ -0,0 +1,104 @@
+package loggo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("AccessLogHandler", func() {
+	var logger *Logger
+	var appender *dummyAppender
+	var msg *Message
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte("hello"))
+		}
+	})
+
+	serve := func(opts AccessLogOptions, path string) {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("User-Agent", "test")
+		r.Header.Set("Referer", "http://example.com")
+		r.SetBasicAuth("frank", "secret")
+		logger.AccessLogHandler(handler, opts).ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	BeforeEach(func() {
+		logger = New("access")
+		appender = &dummyAppender{}
+		logger.SetFormat("{{.LevelStr}} {{.Content}}")
+		logger.DisablePadding()
+		logger.SetNowFunc(func() time.Time {
+			return time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600))
+		})
+		logger.AddAppender(appender, 0)
+		logger.AddAppenderWithFilter(appender, FilterFunc(func(m *Message) bool {
+			msg = m
+			return false
+		}), 0)
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+	})
+
+	It("should use the common log format", func() {
+		serve(AccessLogOptions{Format: AccessLogCommon}, "/foo?bar=1")
+		Expect(appender.str).To(Equal(`INFO 192.0.2.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /foo?bar=1 HTTP/1.1" 200 5` + "\n"))
+	})
+
+	It("should use the combined log format", func() {
+		serve(AccessLogOptions{Format: AccessLogCombined}, "/broken")
+		Expect(appender.str).To(Equal(`ERROR 192.0.2.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /broken HTTP/1.1" 500 - "http://example.com" "test"` + "\n"))
+	})
+
+	It("should store the request information in fields", func() {
+		serve(AccessLogOptions{Format: AccessLogStructured}, "/missing")
+		Expect(appender.str).To(Equal("WARNING GET /missing 404\n"))
+		Expect(msg.Fields).To(HaveKeyWithValue("method", "GET"))
+		Expect(msg.Fields).To(HaveKeyWithValue("path", "/missing"))
+		Expect(msg.Fields).To(HaveKeyWithValue("status", 404))
+		Expect(msg.Fields).To(HaveKeyWithValue("bytes", 19))
+		Expect(msg.Fields).To(HaveKeyWithValue("duration", time.Duration(0)))
+		Expect(msg.Fields).To(HaveKeyWithValue("remote_addr", "192.0.2.1:1234"))
+		Expect(msg.Fields).To(HaveKeyWithValue("user_agent", "test"))
+	})
+
+	It("should skip paths", func() {
+		serve(AccessLogOptions{SkipPaths: []string{"/healthz"}}, "/healthz")
+		Expect(appender.str).To(BeEmpty())
+	})
+
+	It("should respect the logger level", func() {
+		logger.SetLevel(Warning)
+		serve(AccessLogOptions{}, "/foo")
+		Expect(appender.str).To(BeEmpty())
+	})
+
+	It("should respect the appender levels", func() {
+		for _, handle := range logger.Appenders() {
+			handle.SetLevel(Warning)
+		}
+		serve(AccessLogOptions{Format: AccessLogStructured}, "/foo")
+		Expect(appender.str).To(BeEmpty())
+		serve(AccessLogOptions{Format: AccessLogStructured}, "/missing")
+		Expect(appender.str).To(Equal("WARNING GET /missing 404\n"))
+	})
+
+	It("should report the middleware as caller", func() {
+		logger.EnableCallerInfo()
+		serve(AccessLogOptions{}, "/foo")
+		Expect(filepath.Base(msg.File)).To(Equal("access_log.go"))
+		Expect(msg.FuncName).To(ContainSubstring("AccessLogHandler"))
+	})
+})
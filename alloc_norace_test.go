@@ -0,0 +1,24 @@
+//go:build !race
+
+package loggo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"testing"
+)
+
+// the race detector drops pooled messages and buffers at random,
+// so enabled calls are only checked without it
+var _ = Describe("Enabled log calls", func() {
+	It("should not allocate with transient appenders", func() {
+		logger := New("allocations")
+		defer logger.Destroy()
+		logger.AddAppender(NewWriterAppender(ioutil.Discard), EmptyFlag)
+		allocs := testing.AllocsPerRun(100, func() {
+			logger.Info("foo")
+		})
+		Expect(allocs).To(BeZero())
+	})
+})
@@ -12,11 +12,25 @@ const (
 	Async = 1 << iota
 )
 
+// appenderContainer holds an appender and its settings.
+// Containers are never modified once added to the configuration,
+// changing the settings replaces the container with an updated copy
+// sharing the same id and state.
 type appenderContainer struct {
+	id       int
 	appender Appender
 	filter   Filter
 	flags    int
-	wlock    sync.Mutex
+	level    Level
+	state    *appenderState
+}
+
+// appenderState is shared by the copies of a container.
+// Once closed, the appender does not receive messages anymore, even from
+// goroutines still using a configuration which contains it.
+type appenderState struct {
+	lock   sync.Mutex
+	closed bool
 }
 
 var (
@@ -29,14 +43,35 @@ type Appender interface {
 	Append(*Message)
 }
 
+// TransientAppender is implemented by the appenders which do not keep the
+// messages, or anything referencing them, once Append returns.
+// The messages only sent to such appenders, and to their filters,
+// are reused by the next log calls, so that logging does not allocate them.
+type TransientAppender interface {
+	Appender
+	// Transient only marks the appender as transient
+	Transient()
+}
+
+// Flusher is implemented by appenders buffering messages
+type Flusher interface {
+	Flush() error
+}
+
 type writerAppender struct {
 	writer io.Writer
 }
 
 func (w *writerAppender) Append(msg *Message) {
-	_, _ = io.WriteString(w.writer, msg.String())
+	buf := getBuffer()
+	*buf = msg.appendTo(*buf)
+	_, _ = w.writer.Write(*buf)
+	putBuffer(buf)
 }
 
+// Transient marks the appender as not keeping the messages
+func (w *writerAppender) Transient() {}
+
 // NewWriterAppender creates a new appender that logs to the given io.Writer
 func NewWriterAppender(writer io.Writer) Appender {
 	return &writerAppender{writer: writer}
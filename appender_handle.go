@@ -0,0 +1,194 @@
+package loggo
+
+import (
+	"fmt"
+	"math"
+)
+
+// LowestLevel is lower than every level. It is the default level
+// of the appenders, which then receive the messages of all levels.
+const LowestLevel Level = math.MinInt32
+
+// AppenderHandle is returned when adding an appender to a logger,
+// and allows to configure or remove this appender at runtime
+type AppenderHandle struct {
+	logger *Logger
+	id     int
+}
+
+// ID returns the identifier of the appender, unique within its logger
+func (h *AppenderHandle) ID() int {
+	return h.id
+}
+
+// container returns the current container of the appender,
+// or nil if it has been removed
+func (h *AppenderHandle) container() *appenderContainer {
+	for _, container := range h.logger.config().appenders {
+		if container.id == h.id {
+			return container
+		}
+	}
+	return nil
+}
+
+// modify replaces the container of the appender by a copy modified by f
+func (h *AppenderHandle) modify(f func(container *appenderContainer)) error {
+	return h.logger.update(func(c *loggerConfig) error {
+		for i, container := range c.appenders {
+			if container.id == h.id {
+				updated := *container
+				f(&updated)
+				appenders := make([]*appenderContainer, len(c.appenders))
+				copy(appenders, c.appenders)
+				appenders[i] = &updated
+				c.appenders = appenders
+				c.updateMinLevel()
+				return nil
+			}
+		}
+		return fmt.Errorf("appender %d is not registered", h.id)
+	})
+}
+
+// Registered returns true until the appender is removed from its logger
+func (h *AppenderHandle) Registered() bool {
+	return h.container() != nil
+}
+
+// Appender returns the appender, or nil if it has been removed
+func (h *AppenderHandle) Appender() Appender {
+	if container := h.container(); container != nil {
+		return container.appender
+	}
+	return nil
+}
+
+// Level returns the minimum level of the messages sent to the appender
+func (h *AppenderHandle) Level() Level {
+	if container := h.container(); container != nil {
+		return container.level
+	}
+	return LowestLevel
+}
+
+// SetLevel sets the minimum level of the messages sent to the appender.
+// Unlike filters, the levels of the appenders are also taken into account
+// by the logger, so that calls with a level lower than the level of every
+// appender are skipped as cheaply as disabled calls.
+func (h *AppenderHandle) SetLevel(level Level) {
+	h.modify(func(container *appenderContainer) {
+		container.level = level
+	})
+}
+
+// Filter returns the filter of the appender, nil if it has none
+func (h *AppenderHandle) Filter() Filter {
+	if container := h.container(); container != nil {
+		return container.filter
+	}
+	return nil
+}
+
+// SetFilter sets the filter of the appender, nil to remove it
+func (h *AppenderHandle) SetFilter(filter Filter) {
+	h.modify(func(container *appenderContainer) {
+		container.filter = filter
+	})
+}
+
+// Flags returns the flags of the appender
+func (h *AppenderHandle) Flags() int {
+	if container := h.container(); container != nil {
+		return container.flags
+	}
+	return EmptyFlag
+}
+
+// SetFlags sets the flags of the appender
+func (h *AppenderHandle) SetFlags(flags int) {
+	h.modify(func(container *appenderContainer) {
+		container.flags = flags
+	})
+}
+
+// Remove removes the appender from its logger, see Logger.RemoveAppender
+func (h *AppenderHandle) Remove() error {
+	return h.logger.RemoveAppender(h)
+}
+
+// Appenders returns the handles of the appenders of the logger,
+// in the order they were added
+func (l *Logger) Appenders() []*AppenderHandle {
+	containers := l.config().appenders
+	handles := make([]*AppenderHandle, len(containers))
+	for i, container := range containers {
+		handles[i] = &AppenderHandle{logger: l, id: container.id}
+	}
+	return handles
+}
+
+// RemoveAppender removes the appender from the logger, then closes it
+// if it implements io.Closer, once the running appends are done.
+// The appender does not receive any message after being closed,
+// even from the goroutines which started logging before the removal.
+func (l *Logger) RemoveAppender(h *AppenderHandle) error {
+	var removed *appenderContainer
+	err := l.update(func(c *loggerConfig) error {
+		if h.logger.loggerCore != l.loggerCore {
+			return fmt.Errorf("appender %d belongs to another logger", h.id)
+		}
+		for i, container := range c.appenders {
+			if container.id == h.id {
+				removed = container
+				appenders := make([]*appenderContainer, 0, len(c.appenders)-1)
+				appenders = append(appenders, c.appenders[:i]...)
+				c.appenders = append(appenders, c.appenders[i+1:]...)
+				c.updateMinLevel()
+				return nil
+			}
+		}
+		return fmt.Errorf("appender %d is not registered", h.id)
+	})
+	if err != nil {
+		return err
+	}
+	l.pending.wait()
+	return l.destroyAppender(removed)
+}
+
+// ReplaceAppender replaces the appender of the handle, keeping its settings,
+// then closes the previous appender if it implements io.Closer.
+// As with RemoveAppender, the previous appender does not receive
+// any message once closed.
+func (l *Logger) ReplaceAppender(h *AppenderHandle, appender Appender) error {
+	if h.logger.loggerCore != l.loggerCore {
+		return fmt.Errorf("appender %d belongs to another logger", h.id)
+	}
+	var replaced appenderContainer
+	err := h.modify(func(container *appenderContainer) {
+		replaced = *container
+		container.appender = appender
+		container.state = &appenderState{}
+	})
+	if err != nil {
+		return err
+	}
+	l.pending.wait()
+	return l.destroyAppender(&replaced)
+}
+
+// updateMinLevel computes the lowest level accepted by the appenders.
+// Without appenders, nothing is filtered out.
+func (c *loggerConfig) updateMinLevel() {
+	if len(c.appenders) == 0 {
+		c.minLevel = LowestLevel
+		return
+	}
+	c.minLevel = c.appenders[0].level
+	for _, container := range c.appenders[1:] {
+		if !container.level.AtLeast(c.minLevel) {
+			c.minLevel = container.level
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package loggo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sync"
+)
+
+var _ = Describe("AppenderHandle", func() {
+	var logger *Logger
+	var debug, errors *dummyAppender
+	var debugHandle, errorHandle *AppenderHandle
+
+	BeforeEach(func() {
+		logger = New("handle")
+		logger.SetFormat("{{.Content}}")
+		debug = &dummyAppender{}
+		errors = &dummyAppender{}
+		debugHandle = logger.AddAppender(debug, 0)
+		errorHandle = logger.AddAppender(errors, 0)
+		errorHandle.SetLevel(Error)
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+	})
+
+	It("should default to every level", func() {
+		Expect(debugHandle.Level()).To(Equal(LowestLevel))
+		Expect(debugHandle.Appender()).To(Equal(debug))
+		Expect(errorHandle.Level()).To(Equal(Error))
+	})
+
+	It("should only send the messages above the appender level", func() {
+		logger.Info("info")
+		logger.Error("error")
+		Expect(debug.str).To(Equal("info\nerror\n"))
+		Expect(errors.str).To(Equal("error\n"))
+	})
+
+	It("should compute the effective level of the logger", func() {
+		debugHandle.SetLevel(Info)
+		Expect(logger.EffectiveLevel()).To(Equal(Info))
+		Expect(logger.Enabled(Debug)).To(BeFalse())
+		Expect(logger.Enabled(Info)).To(BeTrue())
+		logger.SetLevel(Warning)
+		Expect(logger.EffectiveLevel()).To(Equal(Warning))
+		Expect(logger.Enabled(Info)).To(BeFalse())
+	})
+
+	It("should not evaluate calls below every appender level", func() {
+		debugHandle.SetLevel(Warning)
+		called := false
+		logger.Info(Lazy(func() interface{} {
+			called = true
+			return "info"
+		}))
+		Expect(called).To(BeFalse())
+	})
+
+	It("should update the effective level when appenders change", func() {
+		debugHandle.SetLevel(Error)
+		Expect(logger.EffectiveLevel()).To(Equal(Error))
+		logger.AddAppender(&dummyAppender{}, 0).SetLevel(Info)
+		Expect(logger.EffectiveLevel()).To(Equal(Info))
+		logger.Destroy()
+		Expect(logger.Enabled(Debug)).To(BeTrue())
+	})
+
+	It("should list the appenders", func() {
+		handles := logger.Appenders()
+		Expect(handles).To(HaveLen(2))
+		Expect(handles[0].ID()).To(Equal(debugHandle.ID()))
+		Expect(handles[1].ID()).To(Equal(errorHandle.ID()))
+		Expect(handles[1].Appender()).To(Equal(errors))
+		Expect(debugHandle.ID()).NotTo(Equal(errorHandle.ID()))
+	})
+
+	It("should remove and close appenders", func() {
+		closed := &closingAppender{}
+		handle := logger.AddAppender(closed, 0)
+		handle.SetLevel(Trace)
+		Expect(logger.RemoveAppender(handle)).To(Succeed())
+		Expect(closed.closed).To(BeTrue())
+		Expect(handle.Registered()).To(BeFalse())
+		Expect(logger.Appenders()).To(HaveLen(2))
+		Expect(logger.RemoveAppender(handle)).NotTo(Succeed())
+		logger.Info("foo")
+		Expect(closed.str).To(BeEmpty())
+		Expect(debugHandle.Remove()).To(Succeed())
+		Expect(logger.EffectiveLevel()).To(Equal(Error))
+	})
+
+	It("should not remove the appenders of other loggers", func() {
+		other := New("other")
+		defer other.Destroy()
+		Expect(other.RemoveAppender(debugHandle)).NotTo(Succeed())
+		Expect(debugHandle.Registered()).To(BeTrue())
+	})
+
+	It("should replace appenders", func() {
+		old := &closingAppender{}
+		handle := logger.AddAppenderWithFilter(old, &MaxLogLevelFilter{MaxLevel: Info}, 0)
+		handle.SetLevel(Info)
+		replacement := &dummyAppender{}
+		Expect(logger.ReplaceAppender(handle, replacement)).To(Succeed())
+		Expect(old.closed).To(BeTrue())
+		Expect(handle.Appender()).To(Equal(replacement))
+		Expect(handle.Level()).To(Equal(Info))
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Error("error")
+		Expect(replacement.str).To(Equal("info\n"))
+	})
+
+	It("should not append to removed or replaced appenders", func() {
+		removed := &strictAppender{}
+		replaced := &strictAppender{}
+		removedHandle := logger.AddAppender(removed, Async)
+		replacedHandle := logger.AddAppender(replaced, 0)
+		stale := logger.config()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					logger.Info("foo")
+				}
+			}()
+		}
+		Expect(removedHandle.Remove()).To(Succeed())
+		Expect(logger.ReplaceAppender(replacedHandle, &dummyAppender{})).To(Succeed())
+		wg.Wait()
+		for _, container := range stale.appenders {
+			if container.appender == removed || container.appender == replaced {
+				logger.makeAppend(container, &Message{Content: "foo"})
+			}
+		}
+		Expect(logger.Flush()).To(Succeed())
+		Expect(removed.lateAppends()).To(BeZero())
+		Expect(replaced.lateAppends()).To(BeZero())
+	})
+
+	It("should change filters and flags at runtime", func() {
+		debugHandle.SetFilter(&MinLogLevelFilter{MinLevel: Warning})
+		logger.Info("info")
+		Expect(debug.str).To(BeEmpty())
+		Expect(debugHandle.Filter()).To(Equal(&MinLogLevelFilter{MinLevel: Warning}))
+		debugHandle.SetFilter(nil)
+		debugHandle.SetFlags(Async)
+		Expect(debugHandle.Flags()).To(Equal(Async))
+		logger.Info("info")
+		Expect(logger.Flush()).To(Succeed())
+		Expect(debug.str).To(Equal("info\n"))
+	})
+})
+
+type closingAppender struct {
+	dummyAppender
+	closed bool
+}
+
+func (c *closingAppender) Close() error {
+	c.closed = true
+	return nil
+}
+
+// strictAppender counts the messages appended after it was closed
+type strictAppender struct {
+	closed bool
+	late   int
+	lock   sync.Mutex
+}
+
+func (s *strictAppender) Append(msg *Message) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		s.late++
+	}
+}
+
+func (s *strictAppender) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *strictAppender) lateAppends() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.late
+}
@@ -0,0 +1,13 @@
+package appenders
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestAppenders(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Appenders Suite")
+}
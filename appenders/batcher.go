@@ -0,0 +1,183 @@
+package appenders
+
+import (
+	"errors"
+	"github.com/claudetech/loggo"
+	"sync"
+	"time"
+)
+
+// defaultQueuedBatches is the number of full batches waiting to be sent
+// before Append blocks
+const defaultQueuedBatches = 16
+
+// ErrAppenderClosed is reported to OnError for the messages appended
+// after the appender was closed, which are dropped
+var ErrAppenderClosed = errors.New("message appended after the appender was closed")
+
+type batchRequest struct {
+	msgs []*loggo.Message
+	// receives the error of the send, when not nil
+	result chan error
+}
+
+// batcher buffers messages and sends them in batches from a background
+// goroutine, so that Append never waits for the network unless the
+// queue of full batches is full. The batches are sent in order.
+type batcher struct {
+	batchSize  int
+	batchBytes int
+	interval   time.Duration
+	send       func(msgs []*loggo.Message) error
+	onError    func(error)
+	msgs       []*loggo.Message
+	size       int
+	closed     bool
+	queue      chan batchRequest
+	done       chan struct{}
+	wg         sync.WaitGroup
+	lock       sync.Mutex
+}
+
+// newBatcher starts sending the batches of at most batchSize messages and batchBytes,
+// 0 for no limit, with send, and the buffered messages every interval, when not 0
+func newBatcher(batchSize int, batchBytes int, interval time.Duration,
+	send func(msgs []*loggo.Message) error, onError func(error)) *batcher {
+	b := &batcher{
+		batchSize:  batchSize,
+		batchBytes: batchBytes,
+		interval:   interval,
+		send:       send,
+		onError:    onError,
+		queue:      make(chan batchRequest, defaultQueuedBatches),
+		done:       make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.sendLoop()
+	if interval > 0 {
+		b.wg.Add(1)
+		go b.flushLoop()
+	}
+	return b
+}
+
+// append buffers the message, queuing the batch if it is full
+func (b *batcher) append(msg *loggo.Message) {
+	size := 0
+	if b.batchBytes > 0 {
+		size = messageSize(msg)
+	}
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		b.report(ErrAppenderClosed)
+		return
+	}
+	b.msgs = append(b.msgs, msg)
+	b.size += size
+	if len(b.msgs) >= b.batchSize || (b.batchBytes > 0 && b.size >= b.batchBytes) {
+		b.queue <- batchRequest{msgs: b.takeMessages()}
+	}
+	b.lock.Unlock()
+}
+
+// flush sends the buffered messages after the queued batches,
+// and returns the error of the last send
+func (b *batcher) flush() error {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return nil
+	}
+	result := make(chan error, 1)
+	b.queue <- batchRequest{msgs: b.takeMessages(), result: result}
+	b.lock.Unlock()
+	return <-result
+}
+
+// close sends the remaining messages and stops the background goroutines.
+// The messages appended afterwards are dropped
+func (b *batcher) close() error {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return nil
+	}
+	b.closed = true
+	result := make(chan error, 1)
+	b.queue <- batchRequest{msgs: b.takeMessages(), result: result}
+	close(b.queue)
+	b.lock.Unlock()
+	close(b.done)
+	err := <-result
+	b.wg.Wait()
+	return err
+}
+
+// takeMessages must be called with the lock held
+func (b *batcher) takeMessages() []*loggo.Message {
+	batch := b.msgs
+	b.msgs = nil
+	b.size = 0
+	return batch
+}
+
+// sendLoop sends the queued batches. It never takes the lock,
+// so that append can wait for the queue while holding it
+func (b *batcher) sendLoop() {
+	defer b.wg.Done()
+	for req := range b.queue {
+		var err error
+		if len(req.msgs) > 0 {
+			err = b.send(req.msgs)
+		}
+		if req.result != nil {
+			req.result <- err
+		} else {
+			b.report(err)
+		}
+	}
+}
+
+func (b *batcher) flushLoop() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.lock.Lock()
+			if !b.closed && len(b.msgs) > 0 {
+				b.queue <- batchRequest{msgs: b.takeMessages()}
+			}
+			b.lock.Unlock()
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *batcher) report(err error) {
+	if err != nil && b.onError != nil {
+		b.onError(err)
+	}
+}
+
+// messageSize estimates the size of the message once encoded,
+// without formatting it
+func messageSize(msg *loggo.Message) int {
+	// room for the level, time and encoding overhead
+	size := 64 + len(msg.Name) + len(msg.File) + len(msg.FuncName) + len(msg.Stack)
+	if content, ok := msg.Content.(string); ok {
+		size += len(content)
+	} else {
+		size += 64
+	}
+	for name, value := range msg.Fields {
+		size += len(name) + 16
+		if str, ok := value.(string); ok {
+			size += len(str)
+		}
+	}
+	return size
+}
@@ -0,0 +1,298 @@
+package appenders
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"github.com/claudetech/loggo"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultEmailTimeout   = 10 * time.Second
+	defaultEmailDigestMax = 100
+
+	// DefaultEmailSubject is the default subject of the emails sent for a single message
+	DefaultEmailSubject = `[{{.Level}}] {{.Name}}: {{truncate 100 (print .Content)}}`
+	// DefaultEmailBody is the default body of the emails sent for a single message
+	DefaultEmailBody = `{{.Content}}
+
+Logger: {{.Name}}
+Level: {{.Level}}
+Time: {{.Time.Format "2006-01-02T15:04:05Z07:00"}}
+{{- if .File}}
+Caller: {{.File}}:{{.Line}}{{end}}
+{{- range fields .}}
+{{.Name}}: {{.Value}}{{end}}
+{{- if .Stack}}
+
+{{.Stack}}{{end}}
+`
+	// DefaultEmailDigestSubject is the default subject of the digest emails
+	DefaultEmailDigestSubject = `[{{.Level}}] {{len .Messages}} log messages`
+	// DefaultEmailDigestBody is the default body of the digest emails
+	DefaultEmailDigestBody = `{{len .Messages}} messages logged between {{.Start.Format "2006-01-02T15:04:05Z07:00"}} and {{.End.Format "2006-01-02T15:04:05Z07:00"}}
+{{range .Messages}}
+{{.Time.Format "2006-01-02T15:04:05Z07:00"}} [{{.Name}}] {{.Level}}: {{.Content}}
+{{- range fields .}} {{.Name}}={{.Value}}{{end}}{{end}}
+`
+)
+
+// EmailOptions configures the email appender
+type EmailOptions struct {
+	// The address of the SMTP server, as host:port
+	Addr string
+	// Authenticates with PLAIN auth when not empty
+	Username string
+	Password string
+	// The sender and the recipients of the emails. From can include a name,
+	// e.g. "Alerts <alerts@example.com>"
+	From string
+	To   []string
+	// The connection is upgraded with STARTTLS when the server supports it.
+	// RequireTLS fails the delivery when it does not
+	RequireTLS bool
+	// The TLS configuration of STARTTLS. Defaults to verifying the host of Addr
+	TLSConfig *tls.Config
+	// Timeout of the connection to the server. Defaults to 10s
+	Timeout time.Duration
+	// The text/template of the subject and body of the emails, rendered with the *loggo.Message.
+	// Templates can use the fields and truncate functions described in WebhookOptions.
+	// Default to DefaultEmailSubject and DefaultEmailBody
+	Subject string
+	Body    string
+	// Accumulates the messages and sends a single email listing them every DigestInterval.
+	// Messages are sent one by one when zero
+	DigestInterval time.Duration
+	// Sends the digest early when it reaches DigestMaxMessages messages. Defaults to 100
+	DigestMaxMessages int
+	// The templates of the digest emails, rendered with an EmailDigest.
+	// Default to DefaultEmailDigestSubject and DefaultEmailDigestBody
+	DigestSubject string
+	DigestBody    string
+	// Called when an email could not be sent. Errors are ignored when nil
+	OnError func(error)
+}
+
+// EmailDigest is the data of the digest templates
+type EmailDigest struct {
+	// The messages of the digest, oldest first
+	Messages []*loggo.Message
+	// The highest level of the messages
+	Level loggo.Level
+	// The times of the first and last messages
+	Start time.Time
+	End   time.Time
+}
+
+// EmailAppender sends messages by email
+type EmailAppender struct {
+	opts       EmailOptions
+	host       string
+	sender     *mail.Address
+	recipients []*mail.Address
+	subject    *template.Template
+	body       *template.Template
+	batcher    *batcher
+}
+
+// NewEmailAppender returns an appender sending messages by email through an SMTP server.
+// Emails are sent from a background goroutine, one per message or, with a digest,
+// every DigestInterval. Close must be called to send the remaining messages.
+func NewEmailAppender(opts EmailOptions) (*EmailAppender, error) {
+	if opts.Addr == "" || opts.From == "" || len(opts.To) == 0 {
+		return nil, fmt.Errorf("email appender requires an address, a sender and recipients")
+	}
+	host, _, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := mail.ParseAddress(opts.From)
+	if err != nil {
+		return nil, err
+	}
+	recipients := make([]*mail.Address, len(opts.To))
+	for i, to := range opts.To {
+		if recipients[i], err = mail.ParseAddress(to); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultEmailTimeout
+	}
+	if opts.DigestMaxMessages <= 0 {
+		opts.DigestMaxMessages = defaultEmailDigestMax
+	}
+	subject, body := opts.Subject, opts.Body
+	if opts.DigestInterval > 0 {
+		subject, body = opts.DigestSubject, opts.DigestBody
+		if subject == "" {
+			subject = DefaultEmailDigestSubject
+		}
+		if body == "" {
+			body = DefaultEmailDigestBody
+		}
+	} else {
+		if subject == "" {
+			subject = DefaultEmailSubject
+		}
+		if body == "" {
+			body = DefaultEmailBody
+		}
+	}
+	a := &EmailAppender{opts: opts, host: host, sender: sender, recipients: recipients}
+	if a.subject, err = parseEmailTemplate("subject", subject); err != nil {
+		return nil, err
+	}
+	if a.body, err = parseEmailTemplate("body", body); err != nil {
+		return nil, err
+	}
+	if opts.DigestInterval > 0 {
+		a.batcher = newBatcher(opts.DigestMaxMessages, 0, opts.DigestInterval, a.sendDigest, opts.OnError)
+	} else {
+		a.batcher = newBatcher(1, 0, 0, a.sendMessages, opts.OnError)
+	}
+	return a, nil
+}
+
+func parseEmailTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"fields": webhookFields,
+		"truncate": func(length int, str string) string {
+			return slackTruncate(str, length)
+		},
+	}).Parse(text)
+}
+
+// Append queues the message, or adds it to the digest.
+// Messages appended after Close are dropped and reported to OnError
+func (a *EmailAppender) Append(msg *loggo.Message) {
+	a.batcher.append(msg)
+}
+
+// Flush sends the queued messages and the digest of the buffered messages
+func (a *EmailAppender) Flush() error {
+	return a.batcher.flush()
+}
+
+// Close stops the background digest and sends the remaining messages.
+// Calling Close more than once has no effect
+func (a *EmailAppender) Close() error {
+	return a.batcher.close()
+}
+
+// sendMessages sends an email for each message
+func (a *EmailAppender) sendMessages(msgs []*loggo.Message) (err error) {
+	for _, msg := range msgs {
+		if e := a.send(msg); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+func (a *EmailAppender) sendDigest(msgs []*loggo.Message) error {
+	digest := &EmailDigest{
+		Messages: msgs,
+		Level:    msgs[0].Level,
+		Start:    msgs[0].Time,
+		End:      msgs[len(msgs)-1].Time,
+	}
+	for _, msg := range msgs {
+		if !digest.Level.AtLeast(msg.Level) {
+			digest.Level = msg.Level
+		}
+	}
+	return a.send(digest)
+}
+
+// send renders the templates with data and sends the email
+func (a *EmailAppender) send(data interface{}) error {
+	subject := &strings.Builder{}
+	if err := a.subject.Execute(subject, data); err != nil {
+		return err
+	}
+	body := &strings.Builder{}
+	if err := a.body.Execute(body, data); err != nil {
+		return err
+	}
+	return a.deliver(a.makeEmail(subject.String(), body.String()))
+}
+
+func (a *EmailAppender) makeEmail(subject string, body string) []byte {
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "From: %s\r\n", a.sender.String())
+	recipients := make([]string, len(a.recipients))
+	for i, recipient := range a.recipients {
+		recipients[i] = recipient.String()
+	}
+	fmt.Fprintf(buffer, "To: %s\r\n", strings.Join(recipients, ", "))
+	// subjects are single lines
+	subject = strings.Join(strings.Fields(subject), " ")
+	fmt.Fprintf(buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buffer.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	writer := quotedprintable.NewWriter(buffer)
+	writer.Write([]byte(strings.Replace(body, "\n", "\r\n", -1)))
+	writer.Close()
+	return buffer.Bytes()
+}
+
+// deliver sends the email, upgrading the connection with STARTTLS when possible
+func (a *EmailAppender) deliver(email []byte) error {
+	conn, err := net.DialTimeout("tcp", a.opts.Addr, a.opts.Timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(a.opts.Timeout))
+	client, err := smtp.NewClient(conn, a.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := a.opts.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: a.host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return err
+		}
+	} else if a.opts.RequireTLS {
+		return fmt.Errorf("SMTP server %s does not support STARTTLS", a.opts.Addr)
+	}
+	if a.opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", a.opts.Username, a.opts.Password, a.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(a.sender.Address); err != nil {
+		return err
+	}
+	for _, recipient := range a.recipients {
+		if err := client.Rcpt(recipient.Address); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(email); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,328 @@
+package appenders
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"github.com/claudetech/loggo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+type stubEmail struct {
+	from    string
+	to      []string
+	auth    string
+	tls     bool
+	subject string
+	body    string
+}
+
+// stubSMTP is a minimal SMTP server recording the received emails
+type stubSMTP struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	received  []*stubEmail
+	lock      sync.Mutex
+}
+
+func newStubSMTP(tlsConfig *tls.Config) *stubSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(BeNil())
+	s := &stubSMTP{listener: listener, tlsConfig: tlsConfig}
+	go s.serve()
+	return s
+}
+
+func (s *stubSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *stubSMTP) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	text := textproto.NewConn(conn)
+	email := &stubEmail{}
+	text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO":
+			if s.tlsConfig != nil && !email.tls {
+				text.PrintfLine("250-localhost\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			} else {
+				text.PrintfLine("250-localhost\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			conn = tls.Server(conn, s.tlsConfig)
+			text = textproto.NewConn(conn)
+			email.tls = true
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+			email.auth = string(credentials)
+			text.PrintfLine("235 authenticated")
+		case "MAIL":
+			email.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			email.to = append(email.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.record(email, data)
+			text.PrintfLine("250 ok")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *stubSMTP) record(email *stubEmail, data []byte) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	Expect(err).To(BeNil())
+	email.subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	Expect(err).To(BeNil())
+	body, err := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+	Expect(err).To(BeNil())
+	email.body = strings.Replace(string(body), "\r\n", "\n", -1)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.received = append(s.received, email)
+}
+
+func (s *stubSMTP) emails() []*stubEmail {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*stubEmail(nil), s.received...)
+}
+
+// selfSignedTLS returns a server and a client configuration for 127.0.0.1
+func selfSignedTLS() (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).To(BeNil())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).To(BeNil())
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+}
+
+var _ = Describe("EmailAppender", func() {
+	var smtpServer *stubSMTP
+	var logger *loggo.Logger
+
+	BeforeEach(func() {
+		smtpServer = newStubSMTP(nil)
+		logger = loggo.New("email")
+		logger.SetNowFunc(func() time.Time { return time.Unix(10, 0).UTC() })
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+		smtpServer.listener.Close()
+	})
+
+	newAppender := func(opts EmailOptions) *EmailAppender {
+		opts.Addr = smtpServer.listener.Addr().String()
+		opts.From = "Alerts <alerts@example.com>"
+		opts.To = []string{"oncall@example.com", "ops@example.com"}
+		appender, err := NewEmailAppender(opts)
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		return appender
+	}
+
+	It("should validate the options", func() {
+		_, err := NewEmailAppender(EmailOptions{Addr: "localhost:25", From: "a@example.com"})
+		Expect(err).NotTo(BeNil())
+		_, err = NewEmailAppender(EmailOptions{Addr: "localhost", From: "a@example.com", To: []string{"b@example.com"}})
+		Expect(err).NotTo(BeNil())
+		_, err = NewEmailAppender(EmailOptions{Addr: "localhost:25", From: "a@example.com", To: []string{"b@example.com"}, Subject: "{{.Content"})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should reject addresses injecting headers", func() {
+		_, err := NewEmailAppender(EmailOptions{Addr: "localhost:25", From: "a@example.com", To: []string{"b@example.com\r\nBcc: c@example.com"}})
+		Expect(err).NotTo(BeNil())
+		_, err = NewEmailAppender(EmailOptions{Addr: "localhost:25", From: "a@example.com\r\nBcc: c@example.com", To: []string{"b@example.com"}})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should send each message with the default templates", func() {
+		newAppender(EmailOptions{Username: "user", Password: "secret"})
+		ctx := loggo.WithFields(context.Background(), map[string]interface{}{"tenant": "acme"})
+		logger.ErrorCtx(ctx, "disk full é")
+		Eventually(smtpServer.emails).Should(HaveLen(1))
+		emails := smtpServer.emails()
+		email := emails[0]
+		Expect(email.from).To(Equal("alerts@example.com"))
+		Expect(email.to).To(Equal([]string{"oncall@example.com", "ops@example.com"}))
+		Expect(email.auth).To(Equal("\x00user\x00secret"))
+		Expect(email.tls).To(BeFalse())
+		Expect(email.subject).To(Equal("[ERROR] email: disk full é"))
+		Expect(email.body).To(Equal("disk full é\n\nLogger: email\nLevel: ERROR\nTime: 1970-01-01T00:00:10Z\ntenant: acme\n"))
+	})
+
+	It("should render custom templates", func() {
+		newAppender(EmailOptions{
+			Subject: "{{.Level}}\n{{.Content}}",
+			Body:    `{{.Content}} in {{.Name}}`,
+		})
+		logger.Warning("foo")
+		Eventually(smtpServer.emails).Should(HaveLen(1))
+		email := smtpServer.emails()[0]
+		Expect(email.subject).To(Equal("WARNING foo"))
+		Expect(email.body).To(Equal("foo in email\n"))
+	})
+
+	It("should upgrade the connection with STARTTLS", func() {
+		serverTLS, clientTLS := selfSignedTLS()
+		smtpServer.listener.Close()
+		smtpServer = newStubSMTP(serverTLS)
+		newAppender(EmailOptions{Username: "user", Password: "secret", TLSConfig: clientTLS, RequireTLS: true})
+		logger.Error("foo")
+		Eventually(smtpServer.emails).Should(HaveLen(1))
+		emails := smtpServer.emails()
+		Expect(emails[0].tls).To(BeTrue())
+		Expect(emails[0].auth).To(Equal("\x00user\x00secret"))
+	})
+
+	It("should fail when TLS is required but not supported", func() {
+		errs := make(chan error, 1)
+		newAppender(EmailOptions{RequireTLS: true, OnError: func(err error) { errs <- err }})
+		logger.Error("foo")
+		Eventually(errs).Should(Receive(MatchError(ContainSubstring("STARTTLS"))))
+		Expect(smtpServer.emails()).To(BeEmpty())
+	})
+
+	It("should format the headers from the parsed addresses", func() {
+		appender := newAppender(EmailOptions{})
+		email := string(appender.makeEmail("foo", "bar"))
+		Expect(email).To(HavePrefix("From: \"Alerts\" <alerts@example.com>\r\nTo: <oncall@example.com>, <ops@example.com>\r\n"))
+	})
+
+	It("should not send from the logging goroutine", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		defer listener.Close()
+		release := make(chan struct{})
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					<-release
+					conn.Close()
+				}()
+			}
+		}()
+		appender, err := NewEmailAppender(EmailOptions{Addr: listener.Addr().String(), From: "alerts@example.com", To: []string{"oncall@example.com"}})
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logged := make(chan struct{})
+		go func() {
+			logger.Error("foo")
+			logger.Error("bar")
+			close(logged)
+		}()
+		Eventually(logged).Should(BeClosed())
+		close(release)
+		appender.Close()
+	})
+
+	It("should report the messages appended after close", func() {
+		errs := make(chan error, 1)
+		appender := newAppender(EmailOptions{OnError: func(err error) { errs <- err }})
+		Expect(appender.Close()).To(Succeed())
+		appender.Append(&loggo.Message{Level: loggo.Error, Content: "foo"})
+		Expect(errs).To(Receive(Equal(ErrAppenderClosed)))
+		Expect(smtpServer.emails()).To(BeEmpty())
+	})
+
+	It("should send digests", func() {
+		appender := newAppender(EmailOptions{DigestInterval: time.Hour})
+		logger.Info("foo")
+		logger.Error("bar")
+		logger.Warning("baz")
+		Expect(smtpServer.emails()).To(BeEmpty())
+		Expect(appender.Flush()).To(Succeed())
+		emails := smtpServer.emails()
+		Expect(emails).To(HaveLen(1))
+		Expect(emails[0].subject).To(Equal("[ERROR] 3 log messages"))
+		lines := strings.Split(emails[0].body, "\n")
+		Expect(lines).To(Equal([]string{
+			"3 messages logged between 1970-01-01T00:00:10Z and 1970-01-01T00:00:10Z",
+			"",
+			"1970-01-01T00:00:10Z [email] INFO: foo",
+			"1970-01-01T00:00:10Z [email] ERROR: bar",
+			"1970-01-01T00:00:10Z [email] WARNING: baz",
+			"",
+		}))
+		Expect(appender.Flush()).To(Succeed())
+		Expect(smtpServer.emails()).To(HaveLen(1))
+	})
+
+	It("should send digests periodically", func() {
+		newAppender(EmailOptions{DigestInterval: 10 * time.Millisecond})
+		logger.Info("foo")
+		Eventually(func() int { return len(smtpServer.emails()) }).Should(Equal(1))
+	})
+
+	It("should send digests when full and on close", func() {
+		appender := newAppender(EmailOptions{DigestInterval: time.Hour, DigestMaxMessages: 2})
+		logger.Info("foo")
+		logger.Info("bar")
+		Eventually(smtpServer.emails).Should(HaveLen(1))
+		logger.Info("baz")
+		logger.Info("baz")
+		Expect(appender.Close()).To(Succeed())
+		emails := smtpServer.emails()
+		Expect(emails).To(HaveLen(2))
+		Expect(emails[1].body).To(ContainSubstring("INFO: baz"))
+	})
+})
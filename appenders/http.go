@@ -0,0 +1,370 @@
+package appenders
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/claudetech/loggo"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultHTTPBatchSize     = 100
+	defaultHTTPBatchBytes    = 1 << 20
+	defaultHTTPFlushInterval = 5 * time.Second
+	defaultHTTPTimeout       = 10 * time.Second
+	defaultHTTPMaxRetries    = 3
+	defaultHTTPMinBackoff    = 100 * time.Millisecond
+	defaultHTTPMaxBackoff    = 10 * time.Second
+	defaultHTTPMaxRetryAfter = 2 * time.Minute
+)
+
+// HTTPEncoder serializes a batch of messages into the body of a request
+type HTTPEncoder interface {
+	// ContentType returns the Content-Type header of the requests
+	ContentType() string
+	// Encode writes the messages to w
+	Encode(w io.Writer, msgs []*loggo.Message) error
+}
+
+type httpEncoderFunc struct {
+	contentType string
+	encode      func(w io.Writer, msgs []*loggo.Message) error
+}
+
+// NewHTTPEncoder returns an encoder using the given function
+// and sending the given content type
+func NewHTTPEncoder(contentType string, encode func(w io.Writer, msgs []*loggo.Message) error) HTTPEncoder {
+	return &httpEncoderFunc{contentType: contentType, encode: encode}
+}
+
+func (e *httpEncoderFunc) ContentType() string {
+	return e.contentType
+}
+
+func (e *httpEncoderFunc) Encode(w io.Writer, msgs []*loggo.Message) error {
+	return e.encode(w, msgs)
+}
+
+// JSONArrayEncoder encodes a batch as a JSON array
+type JSONArrayEncoder struct {
+	// Returns the value encoded for each message. Defaults to loggo.NewJSONMessage
+	Record func(msg *loggo.Message) interface{}
+}
+
+// ContentType returns application/json
+func (e *JSONArrayEncoder) ContentType() string {
+	return "application/json"
+}
+
+// Encode writes the messages as a JSON array
+func (e *JSONArrayEncoder) Encode(w io.Writer, msgs []*loggo.Message) error {
+	records := make([]interface{}, len(msgs))
+	for i, msg := range msgs {
+		records[i] = makeHTTPRecord(e.Record, msg)
+	}
+	return json.NewEncoder(w).Encode(records)
+}
+
+// NDJSONEncoder encodes a batch as newline delimited JSON, one message per line
+type NDJSONEncoder struct {
+	// Returns the value encoded for each message. Defaults to loggo.NewJSONMessage
+	Record func(msg *loggo.Message) interface{}
+}
+
+// ContentType returns application/x-ndjson
+func (e *NDJSONEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// Encode writes a JSON object per line
+func (e *NDJSONEncoder) Encode(w io.Writer, msgs []*loggo.Message) error {
+	encoder := json.NewEncoder(w)
+	for _, msg := range msgs {
+		if err := encoder.Encode(makeHTTPRecord(e.Record, msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func makeHTTPRecord(record func(msg *loggo.Message) interface{}, msg *loggo.Message) interface{} {
+	if record != nil {
+		return record(msg)
+	}
+	return loggo.NewJSONMessage(msg)
+}
+
+// HTTPBatchSplitter is implemented by the encoders which send
+// a batch in several requests, for example one per destination.
+// The parts are encoded and sent in order
+type HTTPBatchSplitter interface {
+	Split(msgs []*loggo.Message) [][]*loggo.Message
+}
+
+// HTTPResponseHandler is implemented by the encoders which read the
+// successful responses, with the messages sent in the request.
+// The error returned by HandleResponse is reported, without retrying the request
+type HTTPResponseHandler interface {
+	HandleResponse(msgs []*loggo.Message, res *http.Response) error
+}
+
+// HTTPOptions configures the HTTP appender
+type HTTPOptions struct {
+	// The URL the batches are sent to
+	URL string
+	// The method of the requests. Defaults to POST
+	Method string
+	// Headers added to every request
+	Headers map[string]string
+	// Basic authentication, used when Username is not empty
+	Username string
+	Password string
+	// Sent as "Authorization: Bearer <token>" when not empty
+	BearerToken string
+	// Serializes the batches. Defaults to a JSONArrayEncoder
+	Encoder HTTPEncoder
+	// Compresses the requests with gzip
+	Gzip bool
+	// Maximum number of messages sent in a single request. Defaults to 100
+	BatchSize int
+	// Maximum size of a batch, estimated from the length of the content,
+	// fields and caller information of the messages. Defaults to 1MB
+	BatchBytes int
+	// Maximum time a message stays buffered. Defaults to 5s
+	FlushInterval time.Duration
+	// Timeout of a request. Defaults to 10s
+	Timeout time.Duration
+	// Number of retries after a network error or a 5xx or 429 response.
+	// 429 responses are retried after the Retry-After delay, capped to MaxRetryAfter.
+	// Defaults to 3, negative to disable retries
+	MaxRetries int
+	// Delay before the first retry, doubled for each retry up to MaxBackoff,
+	// except for the retries delayed by Retry-After.
+	// A random jitter of up to half of the delay is subtracted.
+	// Defaults to 100ms and 10s
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Maximum delay waited for the Retry-After header of 429 responses,
+	// given in seconds or as an HTTP date. Defaults to 2m
+	MaxRetryAfter time.Duration
+	// Client used to send the requests. Defaults to a client using Timeout
+	HTTPClient *http.Client
+	// Called when a batch could not be sent. Errors are ignored when nil
+	OnError func(error)
+}
+
+// HTTPAppender sends messages in batches to an HTTP endpoint
+type HTTPAppender struct {
+	opts    HTTPOptions
+	batcher *batcher
+	sleep   func(time.Duration)
+}
+
+// HTTPError is returned when the endpoint answers with an unexpected status
+type HTTPError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status %s", e.Status)
+}
+
+// NewHTTPAppender returns an appender sending messages to an HTTP endpoint.
+// Messages are sent in batches from a background goroutine, either when
+// BatchSize messages or BatchBytes are buffered, or every FlushInterval.
+// Close must be called to send the remaining messages.
+func NewHTTPAppender(opts HTTPOptions) (*HTTPAppender, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("HTTP appender URL cannot be empty")
+	}
+	if opts.Method == "" {
+		opts.Method = "POST"
+	}
+	if _, err := http.NewRequest(opts.Method, opts.URL, nil); err != nil {
+		return nil, err
+	}
+	return newHTTPAppender(opts), nil
+}
+
+// newHTTPAppender starts an appender without validating its options
+func newHTTPAppender(opts HTTPOptions) *HTTPAppender {
+	if opts.Method == "" {
+		opts.Method = "POST"
+	}
+	if opts.Encoder == nil {
+		opts.Encoder = &JSONArrayEncoder{}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultHTTPBatchSize
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = defaultHTTPBatchBytes
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultHTTPFlushInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultHTTPTimeout
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = defaultHTTPMaxRetries
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultHTTPMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultHTTPMaxBackoff
+	}
+	if opts.MaxRetryAfter <= 0 {
+		opts.MaxRetryAfter = defaultHTTPMaxRetryAfter
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: opts.Timeout}
+	}
+	a := &HTTPAppender{opts: opts, sleep: time.Sleep}
+	a.batcher = newBatcher(opts.BatchSize, opts.BatchBytes, opts.FlushInterval, a.send, opts.OnError)
+	return a
+}
+
+// Append buffers the message. Full batches are sent in the background,
+// so Append only blocks when the endpoint cannot keep up.
+// Messages appended after Close are dropped and reported to OnError
+func (a *HTTPAppender) Append(msg *loggo.Message) {
+	a.batcher.append(msg)
+}
+
+// Flush sends all the buffered messages
+func (a *HTTPAppender) Flush() error {
+	return a.batcher.flush()
+}
+
+// Close stops the background flush and sends the buffered messages.
+// Calling Close more than once has no effect
+func (a *HTTPAppender) Close() error {
+	return a.batcher.close()
+}
+
+func (a *HTTPAppender) encode(msgs []*loggo.Message) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if !a.opts.Gzip {
+		err := a.opts.Encoder.Encode(buffer, msgs)
+		return buffer.Bytes(), err
+	}
+	writer := gzip.NewWriter(buffer)
+	if err := a.opts.Encoder.Encode(writer, msgs); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// send sends the batch, split in several requests
+// when the encoder implements HTTPBatchSplitter
+func (a *HTTPAppender) send(msgs []*loggo.Message) (err error) {
+	parts := [][]*loggo.Message{msgs}
+	if splitter, ok := a.opts.Encoder.(HTTPBatchSplitter); ok {
+		parts = splitter.Split(msgs)
+	}
+	for _, part := range parts {
+		if e := a.sendRequest(part); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// sendRequest sends the messages in a request, retrying with an exponential backoff
+func (a *HTTPAppender) sendRequest(msgs []*loggo.Message) error {
+	body, err := a.encode(msgs)
+	if err != nil {
+		return err
+	}
+	backoff := a.opts.MinBackoff
+	for attempt := 0; ; attempt++ {
+		retry, retryAfter, err := a.post(msgs, body)
+		if err == nil || !retry || attempt >= a.opts.MaxRetries {
+			return err
+		}
+		if retryAfter > 0 {
+			a.sleep(retryAfter)
+			continue
+		}
+		a.sleep(backoff - time.Duration(rand.Int63n(int64(backoff)/2+1)))
+		if backoff *= 2; backoff > a.opts.MaxBackoff {
+			backoff = a.opts.MaxBackoff
+		}
+	}
+}
+
+// post sends the body, and returns whether the request should be retried on error,
+// and the delay given by the Retry-After header of 429 responses, if any
+func (a *HTTPAppender) post(msgs []*loggo.Message, body []byte) (bool, time.Duration, error) {
+	req, err := http.NewRequest(a.opts.Method, a.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Content-Type", a.opts.Encoder.ContentType())
+	if a.opts.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if a.opts.Username != "" {
+		req.SetBasicAuth(a.opts.Username, a.opts.Password)
+	}
+	if a.opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.opts.BearerToken)
+	}
+	for k, v := range a.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := a.opts.HTTPClient.Do(req)
+	if err != nil {
+		return true, 0, err
+	}
+	defer res.Body.Close()
+	defer func() { _, _ = io.Copy(ioutil.Discard, res.Body) }()
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		if handler, ok := a.opts.Encoder.(HTTPResponseHandler); ok {
+			return false, 0, handler.HandleResponse(msgs, res)
+		}
+		return false, 0, nil
+	}
+	httpErr := &HTTPError{StatusCode: res.StatusCode, Status: res.Status}
+	if res.StatusCode == http.StatusTooManyRequests {
+		retryAfter := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		if retryAfter > a.opts.MaxRetryAfter {
+			retryAfter = a.opts.MaxRetryAfter
+		}
+		return true, retryAfter, httpErr
+	}
+	return res.StatusCode >= 500, 0, httpErr
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, given either
+// in seconds or as an HTTP date. It returns 0 when the header is invalid or in the past
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 || math.IsNaN(seconds) {
+			return 0
+		}
+		if seconds > math.MaxInt64/float64(time.Second) {
+			return math.MaxInt64
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+	return date.Sub(now)
+}
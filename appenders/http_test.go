@@ -0,0 +1,257 @@
+package appenders
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/claudetech/loggo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+type stubEndpoint struct {
+	// statuses returned by the next requests, 200 once empty
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+	lock     sync.Mutex
+}
+
+func (e *stubEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reader = gz
+	}
+	body, _ := ioutil.ReadAll(reader)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.bodies = append(e.bodies, body)
+	e.headers = append(e.headers, r.Header)
+	if len(e.statuses) > 0 {
+		w.WriteHeader(e.statuses[0])
+		e.statuses = e.statuses[1:]
+	}
+}
+
+func (e *stubEndpoint) requests() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.bodies)
+}
+
+func (e *stubEndpoint) body(i int) []byte {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.bodies[i]
+}
+
+func (e *stubEndpoint) header(i int) http.Header {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.headers[i]
+}
+
+func (e *stubEndpoint) messages(i int) []loggo.JSONMessage {
+	var msgs []loggo.JSONMessage
+	Expect(json.Unmarshal(e.body(i), &msgs)).To(Succeed())
+	return msgs
+}
+
+var _ = Describe("HTTPAppender", func() {
+	var endpoint *stubEndpoint
+	var server *httptest.Server
+	var logger *loggo.Logger
+
+	BeforeEach(func() {
+		endpoint = &stubEndpoint{}
+		server = httptest.NewServer(endpoint)
+		logger = loggo.New("http")
+		logger.SetFormat("{{.Content}}")
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+		server.Close()
+	})
+
+	newAppender := func(opts HTTPOptions) *HTTPAppender {
+		opts.URL = server.URL
+		opts.MinBackoff = time.Millisecond
+		appender, err := NewHTTPAppender(opts)
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		return appender
+	}
+
+	It("should validate the options", func() {
+		_, err := NewHTTPAppender(HTTPOptions{})
+		Expect(err).NotTo(BeNil())
+		_, err = NewHTTPAppender(HTTPOptions{URL: "http://localhost", Method: "bad method"})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should send batches as JSON arrays", func() {
+		newAppender(HTTPOptions{BatchSize: 2})
+		logger.Info("foo")
+		Expect(endpoint.requests()).To(Equal(0))
+		logger.Error("bar")
+		Eventually(endpoint.requests).Should(Equal(1))
+		msgs := endpoint.messages(0)
+		Expect(msgs).To(HaveLen(2))
+		Expect(msgs[0].Content).To(Equal("foo"))
+		Expect(msgs[0].Name).To(Equal("http"))
+		Expect(msgs[1].Level).To(Equal("ERROR"))
+		Expect(endpoint.header(0).Get("Content-Type")).To(Equal("application/json"))
+	})
+
+	It("should send batches when reaching the size limit", func() {
+		newAppender(HTTPOptions{BatchBytes: 150})
+		logger.Info("foo")
+		Expect(endpoint.requests()).To(Equal(0))
+		logger.Info("a longer message")
+		Eventually(endpoint.requests).Should(Equal(1))
+		Expect(endpoint.messages(0)).To(HaveLen(2))
+	})
+
+	It("should flush on close and periodically", func() {
+		appender := newAppender(HTTPOptions{FlushInterval: 10 * time.Millisecond})
+		logger.Info("foo")
+		Eventually(endpoint.requests).Should(Equal(1))
+		logger.Info("bar")
+		Expect(appender.Close()).To(Succeed())
+		Expect(endpoint.requests()).To(Equal(2))
+		Expect(appender.Close()).To(Succeed())
+	})
+
+	It("should support NDJSON and custom records", func() {
+		appender := newAppender(HTTPOptions{Encoder: &NDJSONEncoder{
+			Record: func(msg *loggo.Message) interface{} {
+				return map[string]interface{}{"msg": msg.Content}
+			},
+		}})
+		logger.Info("foo")
+		logger.Info("bar")
+		Expect(appender.Flush()).To(Succeed())
+		Expect(endpoint.header(0).Get("Content-Type")).To(Equal("application/x-ndjson"))
+		scanner := bufio.NewScanner(bytes.NewReader(endpoint.body(0)))
+		var lines []string
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		Expect(lines).To(Equal([]string{`{"msg":"foo"}`, `{"msg":"bar"}`}))
+	})
+
+	It("should support custom encoders", func() {
+		appender := newAppender(HTTPOptions{Encoder: NewHTTPEncoder("text/plain", func(w io.Writer, msgs []*loggo.Message) error {
+			for _, msg := range msgs {
+				fmt.Fprint(w, msg.String())
+			}
+			return nil
+		})})
+		logger.Info("foo")
+		logger.Info("bar")
+		Expect(appender.Flush()).To(Succeed())
+		Expect(string(endpoint.body(0))).To(Equal("foo\nbar\n"))
+		Expect(endpoint.header(0).Get("Content-Type")).To(Equal("text/plain"))
+	})
+
+	It("should send headers, authentication and compressed bodies", func() {
+		appender := newAppender(HTTPOptions{
+			Method:   "PUT",
+			Headers:  map[string]string{"X-Tenant": "acme"},
+			Username: "user",
+			Password: "secret",
+			Gzip:     true,
+		})
+		logger.Info("foo")
+		Expect(appender.Flush()).To(Succeed())
+		headers := endpoint.header(0)
+		Expect(headers.Get("X-Tenant")).To(Equal("acme"))
+		Expect(headers.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(headers.Get("Authorization")).To(HavePrefix("Basic "))
+		Expect(endpoint.messages(0)[0].Content).To(Equal("foo"))
+
+		appender = newAppender(HTTPOptions{BearerToken: "token"})
+		logger.Info("bar")
+		Expect(appender.Flush()).To(Succeed())
+		Expect(endpoint.header(1).Get("Authorization")).To(Equal("Bearer token"))
+	})
+
+	It("should retry on server errors", func() {
+		endpoint.statuses = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+		appender := newAppender(HTTPOptions{})
+		logger.Info("foo")
+		Expect(appender.Flush()).To(Succeed())
+		Expect(endpoint.requests()).To(Equal(3))
+		Expect(endpoint.messages(2)[0].Content).To(Equal("foo"))
+	})
+
+	It("should give up after the maximum number of retries", func() {
+		endpoint.statuses = []int{500, 500, 500}
+		errs := make(chan error, 1)
+		newAppender(HTTPOptions{MaxRetries: 2, BatchSize: 1, OnError: func(err error) { errs <- err }})
+		logger.Info("foo")
+		Eventually(errs).Should(Receive(Equal(&HTTPError{StatusCode: 500, Status: "500 Internal Server Error"})))
+		Expect(endpoint.requests()).To(Equal(3))
+	})
+
+	It("should not retry on client errors", func() {
+		endpoint.statuses = []int{http.StatusBadRequest}
+		appender := newAppender(HTTPOptions{})
+		logger.Info("foo")
+		Expect(appender.Flush()).NotTo(Succeed())
+		Expect(endpoint.requests()).To(Equal(1))
+	})
+
+	It("should not send from the logging goroutine", func() {
+		release := make(chan struct{})
+		blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer blocking.Close()
+		appender, err := NewHTTPAppender(HTTPOptions{URL: blocking.URL, BatchSize: 1})
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logged := make(chan struct{})
+		go func() {
+			logger.Info("foo")
+			close(logged)
+		}()
+		Eventually(logged).Should(BeClosed())
+		close(release)
+		Expect(appender.Close()).To(Succeed())
+	})
+
+	It("should report the messages appended after close", func() {
+		errs := make(chan error, 1)
+		appender := newAppender(HTTPOptions{OnError: func(err error) { errs <- err }})
+		Expect(appender.Close()).To(Succeed())
+		appender.Append(&loggo.Message{Content: "foo"})
+		Expect(errs).To(Receive(Equal(ErrAppenderClosed)))
+		Expect(appender.Flush()).To(Succeed())
+		Expect(endpoint.requests()).To(Equal(0))
+	})
+
+	It("should retry on network errors", func() {
+		closed := httptest.NewServer(endpoint)
+		closed.Close()
+		appender, err := NewHTTPAppender(HTTPOptions{URL: closed.URL, MaxRetries: 1, MinBackoff: time.Millisecond})
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logger.Info("foo")
+		Expect(appender.Close()).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,311 @@
+package appenders
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"github.com/claudetech/loggo"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Protocols supported by the OTLP appender
+const (
+	OTLPHTTP = iota
+	OTLPGRPC
+)
+
+const (
+	defaultOTLPBatchSize     = 512
+	defaultOTLPFlushInterval = 5 * time.Second
+	defaultOTLPTimeout       = 10 * time.Second
+	otlpScopeName            = "github.com/claudetech/loggo"
+)
+
+// OTLPOptions configures the OTLP appender
+type OTLPOptions struct {
+	// The collector endpoint.
+	// With OTLPHTTP, the full URL of the logs endpoint, e.g. http://localhost:4318/v1/logs
+	// With OTLPGRPC, the address of the collector, e.g. localhost:4317
+	Endpoint string
+	// OTLPHTTP or OTLPGRPC, defaults to OTLPHTTP
+	Protocol int
+	// Headers added to every export request
+	Headers map[string]string
+	// The service.name resource attribute
+	ServiceName string
+	// Additional resource attributes
+	ResourceAttributes map[string]string
+	// Maximum number of records sent in a single request. Defaults to 512
+	BatchSize int
+	// Maximum time a record stays buffered. Defaults to 5s
+	FlushInterval time.Duration
+	// Timeout of an export request. Defaults to 10s
+	Timeout time.Duration
+	// Client used with OTLPHTTP. Defaults to a client using Timeout
+	HTTPClient *http.Client
+	// Disables TLS with OTLPGRPC
+	Insecure bool
+	// Called when an export fails. Errors are ignored when nil
+	OnError func(error)
+}
+
+// OTLPAppender exports messages as OTLP log records to an OpenTelemetry collector
+type OTLPAppender struct {
+	opts     OTLPOptions
+	resource *resourcepb.Resource
+	conn     *grpc.ClientConn
+	client   collogspb.LogsServiceClient
+	batcher  *batcher
+	closed   sync.Once
+}
+
+// NewOTLPAppender returns an appender exporting messages to an OpenTelemetry collector.
+// Records are sent in batches from a background goroutine, either when BatchSize
+// records are buffered or every FlushInterval. Close must be called to send the remaining records.
+func NewOTLPAppender(opts OTLPOptions) (*OTLPAppender, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultOTLPBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultOTLPFlushInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultOTLPTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: opts.Timeout}
+	}
+	a := &OTLPAppender{
+		opts:     opts,
+		resource: makeOTLPResource(opts),
+	}
+	switch opts.Protocol {
+	case OTLPHTTP:
+	case OTLPGRPC:
+		creds := credentials.NewTLS(&tls.Config{})
+		if opts.Insecure {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(opts.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+		a.conn = conn
+		a.client = collogspb.NewLogsServiceClient(conn)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %d", opts.Protocol)
+	}
+	a.batcher = newBatcher(opts.BatchSize, 0, opts.FlushInterval, a.send, opts.OnError)
+	return a, nil
+}
+
+// Append buffers the message. Full batches are sent in the background,
+// so Append only blocks when the collector cannot keep up.
+// Messages appended after Close are dropped and reported to OnError
+func (a *OTLPAppender) Append(msg *loggo.Message) {
+	a.batcher.append(msg)
+}
+
+// Flush sends all the buffered records
+func (a *OTLPAppender) Flush() error {
+	return a.batcher.flush()
+}
+
+// Close stops the background flush, sends the buffered records
+// and closes the connection to the collector.
+// Calling Close more than once has no effect
+func (a *OTLPAppender) Close() (err error) {
+	a.closed.Do(func() {
+		err = a.batcher.close()
+		if a.conn != nil {
+			if e := a.conn.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return
+}
+
+func (a *OTLPAppender) send(msgs []*loggo.Message) error {
+	records := make([]*logspb.LogRecord, len(msgs))
+	for i, msg := range msgs {
+		records[i] = makeOTLPRecord(msg)
+	}
+	return a.export(records)
+}
+
+func (a *OTLPAppender) export(records []*logspb.LogRecord) error {
+	req := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: a.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: otlpScopeName},
+				LogRecords: records,
+			}},
+		}},
+	}
+	if a.client != nil {
+		return a.exportGRPC(req)
+	}
+	return a.exportHTTP(req)
+}
+
+func (a *OTLPAppender) exportGRPC(req *collogspb.ExportLogsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.Timeout)
+	defer cancel()
+	if len(a.opts.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(a.opts.Headers))
+	}
+	_, err := a.client.Export(ctx, req)
+	return err
+}
+
+func (a *OTLPAppender) exportHTTP(req *collogspb.ExportLogsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest("POST", a.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range a.opts.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	res, err := a.opts.HTTPClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("OTLP export failed with status %s", res.Status)
+	}
+	return nil
+}
+
+func makeOTLPResource(opts OTLPOptions) *resourcepb.Resource {
+	attrs := make(map[string]interface{}, len(opts.ResourceAttributes)+1)
+	for k, v := range opts.ResourceAttributes {
+		attrs[k] = v
+	}
+	if opts.ServiceName != "" {
+		attrs["service.name"] = opts.ServiceName
+	}
+	return &resourcepb.Resource{Attributes: makeOTLPAttributes(attrs)}
+}
+
+// otlpSeverity maps the level to the OTLP severity of the closest
+// built-in level below it. Custom levels above Fatal use FATAL4.
+// See https://opentelemetry.io/docs/specs/otel/logs/data-model/#field-severitynumber
+func otlpSeverity(level loggo.Level) logspb.SeverityNumber {
+	builtins := []struct {
+		level    loggo.Level
+		severity logspb.SeverityNumber
+	}{
+		{loggo.Fatal, logspb.SeverityNumber_SEVERITY_NUMBER_FATAL},
+		{loggo.Error, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR},
+		{loggo.Warning, logspb.SeverityNumber_SEVERITY_NUMBER_WARN},
+		{loggo.Info, logspb.SeverityNumber_SEVERITY_NUMBER_INFO},
+		{loggo.Debug, logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG},
+	}
+	if !loggo.Fatal.AtLeast(level) {
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL4
+	}
+	for _, builtin := range builtins {
+		if level.AtLeast(builtin.level) {
+			return builtin.severity
+		}
+	}
+	return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE
+}
+
+func makeOTLPRecord(msg *loggo.Message) *logspb.LogRecord {
+	attrs := map[string]interface{}{"logger.name": msg.Name}
+	if msg.File != "" {
+		attrs["code.filepath"] = msg.File
+		attrs["code.lineno"] = msg.Line
+		attrs["code.function"] = msg.FuncName
+	}
+	if msg.Stack != "" {
+		attrs["exception.stacktrace"] = msg.Stack
+	}
+	if len(msg.Causes) > 0 {
+		attrs["exception.type"] = fmt.Sprintf("%T", msg.Causes[0])
+		attrs["exception.message"] = msg.Causes[0].Error()
+	}
+	for k, v := range msg.Fields {
+		switch k {
+		case loggo.TraceIDField, loggo.SpanIDField, loggo.TraceFlagsField:
+		default:
+			attrs[k] = v
+		}
+	}
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(msg.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       otlpSeverity(msg.Level),
+		SeverityText:         msg.Level.String(),
+		Body:                 makeOTLPValue(fmt.Sprint(msg.Content)),
+		Attributes:           makeOTLPAttributes(attrs),
+	}
+	if traceID, err := hex.DecodeString(msg.TraceID()); err == nil && len(traceID) == 16 {
+		record.TraceId = traceID
+	}
+	if spanID, err := hex.DecodeString(msg.SpanID()); err == nil && len(spanID) == 8 {
+		record.SpanId = spanID
+	}
+	if flags, err := hex.DecodeString(msg.TraceFlags()); err == nil && len(flags) == 1 {
+		record.Flags = uint32(flags[0])
+	}
+	return record
+}
+
+func makeOTLPAttributes(attrs map[string]interface{}) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]*commonpb.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, &commonpb.KeyValue{Key: k, Value: makeOTLPValue(attrs[k])})
+	}
+	return kvs
+}
+
+func makeOTLPValue(v interface{}) *commonpb.AnyValue {
+	switch value := v.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: value}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(value)}}
+	case int32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(value)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: value}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(value)}}
+	}
+}
@@ -0,0 +1,230 @@
+package appenders
+
+import (
+	"context"
+	"github.com/claudetech/loggo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+type stubCollector struct {
+	collogspb.UnimplementedLogsServiceServer
+	requests []*collogspb.ExportLogsServiceRequest
+	lock     sync.Mutex
+}
+
+func (c *stubCollector) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.requests = append(c.requests, req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (c *stubCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	req := &collogspb.ExportLogsServiceRequest{}
+	if r.Header.Get("Content-Type") != "application/x-protobuf" || proto.Unmarshal(body, req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.Export(r.Context(), req)
+}
+
+func (c *stubCollector) records() []*logspb.LogRecord {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var records []*logspb.LogRecord
+	for _, req := range c.requests {
+		records = append(records, req.ResourceLogs[0].ScopeLogs[0].LogRecords...)
+	}
+	return records
+}
+
+var _ = Describe("OTLPAppender", func() {
+	var collector *stubCollector
+	var logger *loggo.Logger
+
+	BeforeEach(func() {
+		collector = &stubCollector{}
+		logger = loggo.New("otlp")
+		logger.SetNowFunc(func() time.Time { return time.Unix(10, 0) })
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+	})
+
+	Describe("over HTTP", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(collector)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should export batches", func() {
+			appender, err := NewOTLPAppender(OTLPOptions{
+				Endpoint:    server.URL,
+				ServiceName: "svc",
+				BatchSize:   2,
+			})
+			Expect(err).To(BeNil())
+			logger.AddAppender(appender, loggo.EmptyFlag)
+			logger.Info("foo")
+			Expect(collector.records()).To(BeEmpty())
+			logger.Error("bar")
+			Eventually(collector.records).Should(HaveLen(2))
+			records := collector.records()
+			Expect(records[0].Body.GetStringValue()).To(Equal("foo"))
+			Expect(records[0].SeverityNumber).To(Equal(logspb.SeverityNumber_SEVERITY_NUMBER_INFO))
+			Expect(records[0].TimeUnixNano).To(Equal(uint64(10 * time.Second)))
+			Expect(records[1].SeverityText).To(Equal("ERROR"))
+			resource := collector.requests[0].ResourceLogs[0].Resource
+			Expect(resource.Attributes[0].Key).To(Equal("service.name"))
+			Expect(resource.Attributes[0].Value.GetStringValue()).To(Equal("svc"))
+			Expect(appender.Close()).To(BeNil())
+		})
+
+		It("should flush on close", func() {
+			appender, err := NewOTLPAppender(OTLPOptions{Endpoint: server.URL})
+			Expect(err).To(BeNil())
+			logger.AddAppender(appender, loggo.EmptyFlag)
+			logger.Info("foo")
+			Expect(collector.records()).To(BeEmpty())
+			Expect(appender.Close()).To(BeNil())
+			Expect(collector.records()).To(HaveLen(1))
+		})
+
+		It("should flush periodically", func() {
+			appender, err := NewOTLPAppender(OTLPOptions{
+				Endpoint:      server.URL,
+				FlushInterval: 10 * time.Millisecond,
+			})
+			Expect(err).To(BeNil())
+			defer appender.Close()
+			logger.AddAppender(appender, loggo.EmptyFlag)
+			logger.Info("foo")
+			Eventually(collector.records).Should(HaveLen(1))
+		})
+
+		It("should attach trace information", func() {
+			appender, err := NewOTLPAppender(OTLPOptions{Endpoint: server.URL})
+			Expect(err).To(BeNil())
+			appender.Append(&loggo.Message{
+				Name:    "otlp",
+				Content: "foo",
+				Fields: map[string]interface{}{
+					loggo.TraceIDField:    "0102030405060708090a0b0c0d0e0f10",
+					loggo.SpanIDField:     "0102030405060708",
+					loggo.TraceFlagsField: "01",
+					"tenant":              "acme",
+				},
+			})
+			Expect(appender.Close()).To(BeNil())
+			record := collector.records()[0]
+			Expect(record.TraceId).To(HaveLen(16))
+			Expect(record.SpanId).To(HaveLen(8))
+			Expect(record.Flags).To(Equal(uint32(1)))
+			keys := []string{}
+			for _, attr := range record.Attributes {
+				keys = append(keys, attr.Key)
+			}
+			Expect(keys).To(Equal([]string{"logger.name", "tenant"}))
+		})
+
+		It("should report errors", func() {
+			errs := make(chan error, 1)
+			notFound := httptest.NewServer(http.NotFoundHandler())
+			defer notFound.Close()
+			appender, err := NewOTLPAppender(OTLPOptions{
+				Endpoint:  notFound.URL,
+				BatchSize: 1,
+				OnError:   func(err error) { errs <- err },
+			})
+			Expect(err).To(BeNil())
+			appender.Append(&loggo.Message{Content: "foo"})
+			Eventually(errs).Should(Receive(MatchError(ContainSubstring("404"))))
+			Expect(appender.Close()).To(BeNil())
+		})
+
+		It("should not export from the logging goroutine", func() {
+			release := make(chan struct{})
+			blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+				collector.ServeHTTP(w, r)
+			}))
+			defer blocking.Close()
+			appender, err := NewOTLPAppender(OTLPOptions{Endpoint: blocking.URL, BatchSize: 1})
+			Expect(err).To(BeNil())
+			logger.AddAppender(appender, loggo.EmptyFlag)
+			logged := make(chan struct{})
+			go func() {
+				logger.Info("foo")
+				logger.Info("bar")
+				close(logged)
+			}()
+			Eventually(logged).Should(BeClosed())
+			close(release)
+			Expect(appender.Close()).To(BeNil())
+			records := collector.records()
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Body.GetStringValue()).To(Equal("foo"))
+			Expect(records[1].Body.GetStringValue()).To(Equal("bar"))
+		})
+
+		It("should report the messages appended after close", func() {
+			errs := make(chan error, 1)
+			appender, err := NewOTLPAppender(OTLPOptions{Endpoint: server.URL, BatchSize: 1, OnError: func(err error) { errs <- err }})
+			Expect(err).To(BeNil())
+			Expect(appender.Close()).To(BeNil())
+			appender.Append(&loggo.Message{Content: "foo"})
+			Expect(errs).To(Receive(Equal(ErrAppenderClosed)))
+			Expect(collector.records()).To(BeEmpty())
+		})
+	})
+
+	It("should map levels to severities", func() {
+		Expect(otlpSeverity(loggo.Trace)).To(Equal(logspb.SeverityNumber_SEVERITY_NUMBER_TRACE))
+		Expect(otlpSeverity(loggo.Debug)).To(Equal(logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG))
+		Expect(otlpSeverity(loggo.Info)).To(Equal(logspb.SeverityNumber_SEVERITY_NUMBER_INFO))
+		Expect(otlpSeverity(loggo.Error)).To(Equal(logspb.SeverityNumber_SEVERITY_NUMBER_ERROR))
+		Expect(otlpSeverity(loggo.Fatal + 10)).To(Equal(logspb.SeverityNumber_SEVERITY_NUMBER_FATAL4))
+	})
+
+	Describe("over gRPC", func() {
+		It("should export records", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			server := grpc.NewServer()
+			collogspb.RegisterLogsServiceServer(server, collector)
+			go server.Serve(listener)
+			defer server.Stop()
+
+			appender, err := NewOTLPAppender(OTLPOptions{
+				Endpoint: listener.Addr().String(),
+				Protocol: OTLPGRPC,
+				Insecure: true,
+			})
+			Expect(err).To(BeNil())
+			logger.AddAppender(appender, loggo.EmptyFlag)
+			logger.Warning("foo")
+			Expect(appender.Close()).To(BeNil())
+			records := collector.records()
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].SeverityNumber).To(Equal(logspb.SeverityNumber_SEVERITY_NUMBER_WARN))
+		})
+	})
+})
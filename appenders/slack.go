@@ -1,44 +1,378 @@
 package appenders
 
 import (
-	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/claudetech/loggo"
+	"io"
 	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
-type slackAppender struct {
-	url     string
-	client  *http.Client
-	message *slackMessage
+const (
+	defaultSlackAPIURL        = "https://slack.com/api/chat.postMessage"
+	defaultSlackBatchSize     = 20
+	defaultSlackBatchInterval = time.Second
+	defaultSlackRetryDelay    = time.Second
+	defaultSlackThreadTTL     = time.Hour
+	// maximum length of the text of a section block
+	slackMaxTextLen = 3000
+	// maximum number of fields of a section block
+	slackMaxFields = 10
+)
+
+// SlackOptions configures the Slack appender
+type SlackOptions struct {
+	// The incoming webhook URL. Ignored when Token is set
+	WebhookURL string
+	// A bot token, to post with the chat.postMessage Web API instead of a webhook.
+	// It is required to post thread replies, see ThreadKey
+	Token string
+	// The Web API URL. Defaults to https://slack.com/api/chat.postMessage
+	APIURL string
+	// The username and icon of the posts, when allowed by the webhook
+	Username  string
+	IconEmoji string
+	// The channel of the posts. Required with Token
+	Channel string
+	// Channels of the messages with a level greater or equal to the key,
+	// e.g. {loggo.Error: "#alerts"}. The highest matching level is used,
+	// and Channel when none matches
+	Channels map[loggo.Level]string
+	// Maximum number of messages sent in a single post. Defaults to 20
+	BatchSize int
+	// Maximum time a message stays buffered, so that bursts are sent
+	// in a single post. Defaults to 1s
+	BatchInterval time.Duration
+	// Timeout of a request. Defaults to 10s
+	Timeout time.Duration
+	// Number of retries after a network error or a 5xx or 429 response,
+	// negative to disable retries. Defaults to 3.
+	// 429 responses are retried after the Retry-After delay, capped to 2m
+	MaxRetries int
+	// Client used to send the requests. Defaults to a client using Timeout
+	HTTPClient *http.Client
+	// With Token, messages with the same non empty key are posted as replies
+	// in the thread of the first of them, until ThreadTTL expires
+	ThreadKey func(msg *loggo.Message) string
+	// How long a thread receives the replies. Defaults to 1h
+	ThreadTTL time.Duration
+	// Called when a post fails. Errors are ignored when nil
+	OnError func(error)
+}
+
+// SlackAppender posts messages to Slack, as attachments colored by level.
+// It is an HTTPAppender encoding the batches as Slack posts
+type SlackAppender struct {
+	*HTTPAppender
+	encoder *slackEncoder
+}
+
+type slackThread struct {
+	channel string
+	ts      string
+	expires time.Time
 }
 
 type slackMessage struct {
-	Icon     string `json:"icon_emoji,omitempty"`
-	Channel  string `json:"channel,omitempty"`
-	Username string `json:"username,omitempty"`
-	Text     string `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Icon        string            `json:"icon_emoji,omitempty"`
+	Text        string            `json:"text"`
+	ThreadTS    string            `json:"thread_ts,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// NewSlackAppender returns an appender posting messages to a Slack incoming webhook.
+// Unlike in previous versions, messages are sent in batches from a background
+// goroutine, so the appender must be flushed or closed to send the remaining ones.
+func NewSlackAppender(url string, username string, icon string, channel string) *SlackAppender {
+	return newSlackAppender(SlackOptions{
+		WebhookURL: url,
+		Username:   username,
+		IconEmoji:  icon,
+		Channel:    channel,
+	})
+}
+
+// NewSlackAppenderWithOptions returns an appender posting messages to Slack,
+// either with an incoming webhook or with the Web API when opts.Token is set.
+// Messages are sent in batches, so the appender must be closed to send the remaining ones.
+func NewSlackAppenderWithOptions(opts SlackOptions) (*SlackAppender, error) {
+	if opts.Token == "" && opts.WebhookURL == "" {
+		return nil, fmt.Errorf("either a webhook URL or a token is required")
+	}
+	if opts.Token != "" && opts.Channel == "" {
+		return nil, fmt.Errorf("a channel is required when using a token")
+	}
+	return newSlackAppender(opts), nil
 }
 
-// Returns an appender that sends messages to Slack
-func NewSlackAppender(url string, username string, icon string, channel string) *slackAppender {
-	client := http.DefaultClient
-	slackMessage := &slackMessage{
-		Username: username,
-		Icon:     icon,
-		Channel:  channel,
+func newSlackAppender(opts SlackOptions) *SlackAppender {
+	if opts.APIURL == "" {
+		opts.APIURL = defaultSlackAPIURL
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultSlackBatchSize
+	}
+	if opts.BatchInterval <= 0 {
+		opts.BatchInterval = defaultSlackBatchInterval
+	}
+	if opts.ThreadTTL <= 0 {
+		opts.ThreadTTL = defaultSlackThreadTTL
+	}
+	encoder := &slackEncoder{
+		opts:    opts,
+		threads: make(map[string]*slackThread),
+		nowFunc: time.Now,
 	}
-	return &slackAppender{
-		url:     url,
-		client:  client,
-		message: slackMessage,
+	url := opts.WebhookURL
+	if opts.Token != "" {
+		url = opts.APIURL
 	}
+	return &SlackAppender{
+		HTTPAppender: newHTTPAppender(HTTPOptions{
+			URL:           url,
+			BearerToken:   opts.Token,
+			Encoder:       encoder,
+			BatchSize:     opts.BatchSize,
+			FlushInterval: opts.BatchInterval,
+			Timeout:       opts.Timeout,
+			MaxRetries:    opts.MaxRetries,
+			MinBackoff:    defaultSlackRetryDelay,
+			HTTPClient:    opts.HTTPClient,
+			OnError:       opts.OnError,
+		}),
+		encoder: encoder,
+	}
+}
+
+// slackEncoder encodes the batches as Slack posts, one per channel and thread.
+// It keeps the threads started by the posts made with the Web API
+type slackEncoder struct {
+	opts    SlackOptions
+	threads map[string]*slackThread
+	nowFunc func() time.Time
+	lock    sync.Mutex
+}
+
+// ContentType returns application/json
+func (e *slackEncoder) ContentType() string {
+	return "application/json; charset=utf-8"
 }
-func (s *slackAppender) Append(msg *loggo.Message) {
-	s.message.Text = msg.String()
-	body, err := json.Marshal(s.message)
-	if err != nil {
-		return
+
+// channel returns the channel of the messages with the given level
+func (e *slackEncoder) channel(level loggo.Level) string {
+	channel := e.opts.Channel
+	best := loggo.LowestLevel
+	found := false
+	for min, c := range e.opts.Channels {
+		if level.AtLeast(min) && (!found || !best.AtLeast(min)) {
+			channel, best, found = c, min, true
+		}
 	}
-	s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	return channel
+}
+
+func (e *slackEncoder) threadKey(msg *loggo.Message) string {
+	if e.opts.Token == "" || e.opts.ThreadKey == nil {
+		return ""
+	}
+	return e.opts.ThreadKey(msg)
+}
+
+// Split groups the messages by channel and thread, and expires the old threads
+func (e *slackEncoder) Split(msgs []*loggo.Message) [][]*loggo.Message {
+	var groups [][]*loggo.Message
+	indexes := make(map[[2]string]int)
+	for _, msg := range msgs {
+		key := [2]string{e.channel(msg.Level), e.threadKey(msg)}
+		i, ok := indexes[key]
+		if !ok {
+			i = len(groups)
+			indexes[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], msg)
+	}
+	now := e.nowFunc()
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for key, thread := range e.threads {
+		if now.After(thread.expires) {
+			delete(e.threads, key)
+		}
+	}
+	return groups
+}
+
+// thread returns the thread the messages are replies to, if any
+func (e *slackEncoder) thread(msgs []*loggo.Message) (string, string, *slackThread) {
+	channel, key := e.channel(msgs[0].Level), e.threadKey(msgs[0])
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if thread, ok := e.threads[key]; ok && key != "" && thread.channel == channel {
+		return channel, key, thread
+	}
+	return channel, key, nil
+}
+
+// Encode writes a post of messages sharing the same channel and thread
+func (e *slackEncoder) Encode(w io.Writer, msgs []*loggo.Message) error {
+	payload := makeSlackMessage(msgs)
+	channel, _, thread := e.thread(msgs)
+	payload.Channel = channel
+	payload.Username = e.opts.Username
+	payload.Icon = e.opts.IconEmoji
+	if thread != nil {
+		payload.ThreadTS = thread.ts
+	}
+	return json.NewEncoder(w).Encode(payload)
+}
+
+// HandleResponse checks the responses of the Web API,
+// and keeps the threads started by the posts
+func (e *slackEncoder) HandleResponse(msgs []*loggo.Message, res *http.Response) error {
+	if e.opts.Token == "" {
+		return nil
+	}
+	apiRes := &slackAPIResponse{}
+	if err := json.NewDecoder(res.Body).Decode(apiRes); err != nil {
+		return err
+	}
+	if !apiRes.OK {
+		return fmt.Errorf("Slack API error: %s", apiRes.Error)
+	}
+	channel, key, thread := e.thread(msgs)
+	if key != "" && thread == nil && apiRes.TS != "" {
+		e.lock.Lock()
+		e.threads[key] = &slackThread{channel: channel, ts: apiRes.TS, expires: e.nowFunc().Add(e.opts.ThreadTTL)}
+		e.lock.Unlock()
+	}
+	return nil
+}
+
+// slackColors are the colors of the attachments of the messages
+// with a level greater or equal to each threshold, so that custom
+// levels use the color of the closest built-in level below them
+var slackColors = []struct {
+	level loggo.Level
+	color string
+}{
+	{loggo.Error, "#e01e5a"},
+	{loggo.Warning, "#ecb22e"},
+	{loggo.Info, "#2eb67d"},
+}
+
+// slackColor returns the color of the attachments for the level
+func slackColor(level loggo.Level) string {
+	for _, threshold := range slackColors {
+		if level.AtLeast(threshold.level) {
+			return threshold.color
+		}
+	}
+	return "#868686"
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackEscape(str string) string {
+	return slackEscaper.Replace(str)
+}
+
+// slackTruncate truncates str to max bytes, on a rune boundary
+func slackTruncate(str string, max int) string {
+	if len(str) <= max {
+		return str
+	}
+	suffix := "..."
+	if max <= len(suffix) {
+		suffix = ""
+	}
+	cut := max - len(suffix)
+	for cut > 0 && !utf8.RuneStart(str[cut]) {
+		cut--
+	}
+	return str[:cut] + suffix
+}
+
+func makeSlackMessage(msgs []*loggo.Message) *slackMessage {
+	first := msgs[0]
+	text := fmt.Sprintf("%s: %v", first.Level, first.Content)
+	if len(msgs) > 1 {
+		text += fmt.Sprintf(" (and %d more)", len(msgs)-1)
+	}
+	payload := &slackMessage{Text: slackEscape(slackTruncate(text, slackMaxTextLen))}
+	for _, msg := range msgs {
+		payload.Attachments = append(payload.Attachments, makeSlackAttachment(msg))
+	}
+	return payload
+}
+
+func makeSlackAttachment(msg *loggo.Message) slackAttachment {
+	content := fmt.Sprintf("*%s* %s", msg.Level, slackEscape(fmt.Sprint(msg.Content)))
+	blocks := []slackBlock{{
+		Type: "section",
+		Text: &slackText{Type: "mrkdwn", Text: slackTruncate(content, slackMaxTextLen)},
+	}}
+	fields := []slackText{
+		makeSlackField("Logger", msg.Name),
+		makeSlackField("Time", msg.Time.Format(time.RFC3339)),
+	}
+	if msg.File != "" {
+		fields = append(fields, makeSlackField("Caller", fmt.Sprintf("%s:%d", filepath.Base(msg.File), msg.Line)))
+	}
+	names := make([]string, 0, len(msg.Fields))
+	for name := range msg.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(fields) == slackMaxFields {
+			break
+		}
+		fields = append(fields, makeSlackField(name, fmt.Sprint(msg.Fields[name])))
+	}
+	blocks = append(blocks, slackBlock{Type: "section", Fields: fields})
+	if msg.Stack != "" {
+		stack := slackTruncate(slackEscape(msg.Stack), slackMaxTextLen-6)
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: "```" + stack + "```"},
+		})
+	}
+	return slackAttachment{Color: slackColor(msg.Level), Blocks: blocks}
+}
+
+func makeSlackField(name, value string) slackText {
+	text := fmt.Sprintf("*%s*\n%s", slackEscape(name), slackEscape(value))
+	return slackText{Type: "mrkdwn", Text: slackTruncate(text, 2000)}
 }
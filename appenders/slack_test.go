@@ -0,0 +1,250 @@
+package appenders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/claudetech/loggo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+type stubSlack struct {
+	// responses to the next requests, 429 ones with the retryAfter
+	// header, or 3 seconds
+	statuses   []int
+	retryAfter string
+	payloads   []*slackMessage
+	tokens     []string
+	lock       sync.Mutex
+}
+
+func (s *stubSlack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		if status == http.StatusTooManyRequests {
+			retryAfter := s.retryAfter
+			if retryAfter == "" {
+				retryAfter = "3"
+			}
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+		return
+	}
+	payload := &slackMessage{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.payloads = append(s.payloads, payload)
+	s.tokens = append(s.tokens, r.Header.Get("Authorization"))
+	if r.URL.Path == "/api/chat.postMessage" {
+		json.NewEncoder(w).Encode(&slackAPIResponse{
+			OK:      true,
+			Channel: payload.Channel,
+			TS:      fmt.Sprintf("100.%d", len(s.payloads)),
+		})
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func (s *stubSlack) posts() []*slackMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*slackMessage(nil), s.payloads...)
+}
+
+var _ = Describe("SlackAppender", func() {
+	var stub *stubSlack
+	var server *httptest.Server
+	var logger *loggo.Logger
+
+	BeforeEach(func() {
+		stub = &stubSlack{}
+		server = httptest.NewServer(stub)
+		logger = loggo.New("slack")
+		logger.SetNowFunc(func() time.Time { return time.Unix(10, 0).UTC() })
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+		server.Close()
+	})
+
+	newAppender := func(opts SlackOptions) *SlackAppender {
+		if opts.Token == "" {
+			opts.WebhookURL = server.URL + "/webhook"
+		} else {
+			opts.APIURL = server.URL + "/api/chat.postMessage"
+		}
+		appender, err := NewSlackAppenderWithOptions(opts)
+		Expect(err).To(BeNil())
+		appender.sleep = func(time.Duration) {}
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		return appender
+	}
+
+	It("should validate the options", func() {
+		_, err := NewSlackAppenderWithOptions(SlackOptions{})
+		Expect(err).NotTo(BeNil())
+		_, err = NewSlackAppenderWithOptions(SlackOptions{Token: "token"})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should post attachments colored by level", func() {
+		appender := NewSlackAppender(server.URL, "bot", ":fire:", "#general")
+		logger.AddAppender(appender, loggo.Async)
+		logger.EnableCallerInfo()
+		logger.WarningCtx(loggo.WithFields(context.Background(), map[string]interface{}{"tenant": "acme"}), "disk <full>")
+		Expect(logger.Flush()).To(Succeed())
+		posts := stub.posts()
+		Expect(posts).To(HaveLen(1))
+		post := posts[0]
+		Expect(post.Username).To(Equal("bot"))
+		Expect(post.Icon).To(Equal(":fire:"))
+		Expect(post.Channel).To(Equal("#general"))
+		Expect(post.Text).To(Equal("WARNING: disk &lt;full&gt;"))
+		attachment := post.Attachments[0]
+		Expect(attachment.Color).To(Equal(slackColor(loggo.Warning)))
+		Expect(attachment.Blocks[0].Text.Text).To(Equal("*WARNING* disk &lt;full&gt;"))
+		fields := attachment.Blocks[1].Fields
+		Expect(fields).To(HaveLen(4))
+		Expect(fields[0].Text).To(Equal("*Logger*\nslack"))
+		Expect(fields[1].Text).To(Equal("*Time*\n1970-01-01T00:00:10Z"))
+		Expect(fields[2].Text).To(HavePrefix("*Caller*\nslack_test.go:"))
+		Expect(fields[3].Text).To(Equal("*tenant*\nacme"))
+	})
+
+	It("should batch bursts into a single post", func() {
+		newAppender(SlackOptions{})
+		for i := 0; i < 5; i++ {
+			logger.Errorf("error %d", i)
+		}
+		Expect(logger.Flush()).To(Succeed())
+		posts := stub.posts()
+		Expect(posts).To(HaveLen(1))
+		Expect(posts[0].Attachments).To(HaveLen(5))
+		Expect(posts[0].Text).To(Equal("ERROR: error 0 (and 4 more)"))
+	})
+
+	It("should post batches periodically and when full", func() {
+		newAppender(SlackOptions{BatchSize: 2, BatchInterval: 10 * time.Millisecond})
+		logger.Info("foo")
+		logger.Info("bar")
+		logger.Info("baz")
+		Eventually(stub.posts).Should(HaveLen(2))
+		Expect(stub.posts()[0].Attachments).To(HaveLen(2))
+	})
+
+	It("should route levels to channels", func() {
+		newAppender(SlackOptions{
+			Channel:  "#logs",
+			Channels: map[loggo.Level]string{loggo.Warning: "#warnings", loggo.Error: "#alerts"},
+		})
+		logger.Info("info")
+		logger.Warning("warning")
+		logger.Fatal("fatal")
+		logger.Error("error")
+		Expect(logger.Flush()).To(Succeed())
+		posts := stub.posts()
+		Expect(posts).To(HaveLen(3))
+		Expect(posts[0].Channel).To(Equal("#logs"))
+		Expect(posts[1].Channel).To(Equal("#warnings"))
+		Expect(posts[2].Channel).To(Equal("#alerts"))
+		Expect(posts[2].Attachments).To(HaveLen(2))
+	})
+
+	It("should respect Retry-After", func() {
+		stub.statuses = []int{http.StatusTooManyRequests, http.StatusBadGateway}
+		appender := newAppender(SlackOptions{})
+		var delays []time.Duration
+		appender.sleep = func(d time.Duration) { delays = append(delays, d) }
+		logger.Info("foo")
+		Expect(logger.Flush()).To(Succeed())
+		Expect(delays).To(HaveLen(2))
+		Expect(delays[0]).To(Equal(3 * time.Second))
+		Expect(delays[1]).To(BeNumerically("~", 750*time.Millisecond, 250*time.Millisecond))
+		Expect(stub.posts()).To(HaveLen(1))
+	})
+
+	It("should not cap Retry-After with the backoff", func() {
+		stub.statuses = []int{http.StatusTooManyRequests, http.StatusTooManyRequests}
+		stub.retryAfter = "45"
+		appender := newAppender(SlackOptions{})
+		var delays []time.Duration
+		appender.sleep = func(d time.Duration) { delays = append(delays, d) }
+		logger.Info("foo")
+		Expect(logger.Flush()).To(Succeed())
+		Expect(delays).To(Equal([]time.Duration{45 * time.Second, 45 * time.Second}))
+		Expect(stub.posts()).To(HaveLen(1))
+	})
+
+	It("should parse Retry-After dates", func() {
+		now := time.Date(2015, time.January, 2, 15, 4, 5, 0, time.UTC)
+		Expect(parseRetryAfter("Fri, 02 Jan 2015 15:04:35 GMT", now)).To(Equal(30 * time.Second))
+		Expect(parseRetryAfter("Fri, 02 Jan 2015 15:04:00 GMT", now)).To(BeZero())
+		Expect(parseRetryAfter("1.5", now)).To(Equal(1500 * time.Millisecond))
+		Expect(parseRetryAfter("-1", now)).To(BeZero())
+		Expect(parseRetryAfter("soon", now)).To(BeZero())
+	})
+
+	It("should not retry client errors", func() {
+		stub.statuses = []int{http.StatusNotFound}
+		newAppender(SlackOptions{})
+		logger.Info("foo")
+		Expect(logger.Flush()).NotTo(Succeed())
+		Expect(stub.posts()).To(BeEmpty())
+	})
+
+	It("should post replies in threads with the Web API", func() {
+		newAppender(SlackOptions{
+			Token:   "xoxb-token",
+			Channel: "#alerts",
+			ThreadKey: func(msg *loggo.Message) string {
+				return msg.ContentFormat
+			},
+		})
+		logger.Errorf("job %s failed", "a")
+		Expect(logger.Flush()).To(Succeed())
+		logger.Errorf("job %s failed", "b")
+		logger.Errorf("other %s", "c")
+		Expect(logger.Flush()).To(Succeed())
+		posts := stub.posts()
+		Expect(posts).To(HaveLen(3))
+		Expect(stub.tokens[0]).To(Equal("Bearer xoxb-token"))
+		Expect(posts[0].ThreadTS).To(BeEmpty())
+		Expect(posts[1].ThreadTS).To(Equal("100.1"))
+		Expect(posts[1].Text).To(Equal("ERROR: job b failed"))
+		Expect(posts[2].ThreadTS).To(BeEmpty())
+	})
+
+	It("should include stack traces", func() {
+		newAppender(SlackOptions{})
+		logger.EnableStackTrace(loggo.Error)
+		logger.Error("foo")
+		Expect(logger.Flush()).To(Succeed())
+		blocks := stub.posts()[0].Attachments[0].Blocks
+		Expect(blocks).To(HaveLen(3))
+		Expect(blocks[2].Text.Text).To(HavePrefix("```"))
+	})
+
+	It("should truncate on rune boundaries", func() {
+		Expect(slackTruncate("héllo", 10)).To(Equal("héllo"))
+		Expect(slackTruncate("ééééé", 8)).To(Equal("éé..."))
+		Expect(slackTruncate("ééééé", 3)).To(Equal("é"))
+	})
+
+	It("should color custom levels", func() {
+		Expect(slackColor(loggo.Fatal + 1)).To(Equal(slackColor(loggo.Error)))
+		Expect(slackColor(loggo.Trace - 1)).To(Equal(slackColor(loggo.Debug)))
+	})
+})
@@ -0,0 +1,210 @@
+package appenders
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/claudetech/loggo"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TeamsTemplate renders an adaptive card for Microsoft Teams incoming webhooks and workflows
+const TeamsTemplate = `{"type": "message", "attachments": [{
+  "contentType": "application/vnd.microsoft.card.adaptive",
+  "content": {
+    "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+    "type": "AdaptiveCard",
+    "version": "1.4",
+    "body": [
+      {"type": "TextBlock", "text": {{json (printf "%s %s" .Level .Name)}}, "weight": "bolder", "color": {{json (adaptiveColor .Level)}}},
+      {"type": "TextBlock", "text": {{json (truncate 10000 (print .Content))}}, "wrap": true},
+      {"type": "FactSet", "facts": [
+        {"title": "Time", "value": {{json (.Time.Format "2006-01-02T15:04:05Z07:00")}}}
+        {{- range fields .}}, {"title": {{json .Name}}, "value": {{json .Value}}}{{end}}
+      ]}
+    ]
+  }
+}]}`
+
+// DiscordTemplate renders an embed for Discord webhooks.
+// The "username" option overrides the name of the webhook
+const DiscordTemplate = `{
+  {{- with option "username"}}"username": {{json .}}, {{end -}}
+  "embeds": [{
+    "title": {{json (truncate 256 (printf "%s %s" .Level .Name))}},
+    "description": {{json (truncate 4096 (print .Content))}},
+    "color": {{intColor .Level}},
+    "timestamp": {{json .Time}},
+    "fields": [
+      {{- range $i, $f := fields .}}{{if $i}}, {{end}}{"name": {{json (truncate 256 $f.Name)}}, "value": {{json (truncate 1024 $f.Value)}}, "inline": true}{{end -}}
+    ]
+  }]
+}`
+
+// MattermostTemplate renders an attachment for Mattermost incoming webhooks.
+// The "username" and "channel" options override the ones of the webhook
+const MattermostTemplate = `{
+  {{- with option "username"}}"username": {{json .}}, {{end -}}
+  {{- with option "channel"}}"channel": {{json .}}, {{end -}}
+  "attachments": [{
+    "fallback": {{json (printf "%s: %v" .Level .Content)}},
+    "color": {{json (hexColor .Level)}},
+    "title": {{json (printf "%s %s" .Level .Name)}},
+    "text": {{json (print .Content)}},
+    "fields": [
+      {{- range $i, $f := fields .}}{{if $i}}, {{end}}{"short": true, "title": {{json $f.Name}}, "value": {{json $f.Value}}}{{end -}}
+    ],
+    "ts": {{.Time.Unix}}
+  }]
+}`
+
+// WebhookOptions configures a webhook appender
+type WebhookOptions struct {
+	// The URL of the webhook
+	URL string
+	// The text/template rendering the body of the request from the *loggo.Message.
+	// Besides the standard functions, templates can use:
+	//   json VALUE: the JSON encoding of the value, e.g. {{json (print .Content)}}
+	//   option NAME: the value of Options[NAME]
+	//   fields MESSAGE: the fields of the message, sorted by name, as a list of {Name, Value} strings
+	//   truncate LENGTH STRING: the string truncated to LENGTH bytes
+	//   hexColor LEVEL, intColor LEVEL: the color of the level, as "#rrggbb" or an integer
+	//   adaptiveColor LEVEL: the adaptive card color of the level
+	Template string
+	// Values available in the template with the option function
+	Options map[string]string
+	// The Content-Type of the requests. Defaults to application/json
+	ContentType string
+	// Headers added to every request
+	Headers map[string]string
+	// Timeout of a request. Defaults to 10s
+	Timeout time.Duration
+	// Number of retries, see HTTPOptions. Defaults to 3
+	MaxRetries int
+	// Client used to send the requests. Defaults to a client using Timeout
+	HTTPClient *http.Client
+	// Called when a request fails. Errors are ignored when nil
+	OnError func(error)
+}
+
+// WebhookAppender posts each message to a webhook, with a body rendered by a template
+type WebhookAppender struct {
+	*HTTPAppender
+}
+
+// WebhookField is a field of a message, as returned by the fields template function
+type WebhookField struct {
+	Name  string
+	Value string
+}
+
+// NewWebhookAppender returns an appender posting the messages to a webhook,
+// rendering the body of the requests with opts.Template.
+// The requests are sent and retried by a background goroutine,
+// so the appender must be closed to post the last messages.
+func NewWebhookAppender(opts WebhookOptions) (*WebhookAppender, error) {
+	tpl, err := template.New("webhook").Funcs(webhookFuncs(opts.Options)).Parse(opts.Template)
+	if err != nil {
+		return nil, err
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	encoder := NewHTTPEncoder(contentType, func(w io.Writer, msgs []*loggo.Message) error {
+		for _, msg := range msgs {
+			if err := tpl.Execute(w, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	appender, err := NewHTTPAppender(HTTPOptions{
+		URL:        opts.URL,
+		Headers:    opts.Headers,
+		Encoder:    encoder,
+		BatchSize:  1,
+		Timeout:    opts.Timeout,
+		MaxRetries: opts.MaxRetries,
+		HTTPClient: opts.HTTPClient,
+		OnError:    opts.OnError,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookAppender{appender}, nil
+}
+
+// NewTeamsAppender returns an appender posting messages to a Microsoft Teams webhook
+func NewTeamsAppender(url string) (*WebhookAppender, error) {
+	return NewWebhookAppender(WebhookOptions{URL: url, Template: TeamsTemplate})
+}
+
+// NewDiscordAppender returns an appender posting messages to a Discord webhook.
+// username can be empty to use the name of the webhook
+func NewDiscordAppender(url string, username string) (*WebhookAppender, error) {
+	return NewWebhookAppender(WebhookOptions{
+		URL:      url,
+		Template: DiscordTemplate,
+		Options:  map[string]string{"username": username},
+	})
+}
+
+// NewMattermostAppender returns an appender posting messages to a Mattermost webhook.
+// username and channel can be empty to use the ones of the webhook
+func NewMattermostAppender(url string, username string, channel string) (*WebhookAppender, error) {
+	return NewWebhookAppender(WebhookOptions{
+		URL:      url,
+		Template: MattermostTemplate,
+		Options:  map[string]string{"username": username, "channel": channel},
+	})
+}
+
+func webhookFuncs(options map[string]string) template.FuncMap {
+	return template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"option": func(name string) string {
+			return options[name]
+		},
+		"fields": webhookFields,
+		"truncate": func(length int, str string) string {
+			return slackTruncate(str, length)
+		},
+		"hexColor": slackColor,
+		"intColor": func(level loggo.Level) int64 {
+			color, _ := strconv.ParseInt(strings.TrimPrefix(slackColor(level), "#"), 16, 64)
+			return color
+		},
+		"adaptiveColor": func(level loggo.Level) string {
+			switch {
+			case level.AtLeast(loggo.Error):
+				return "attention"
+			case level.AtLeast(loggo.Warning):
+				return "warning"
+			case level.AtLeast(loggo.Info):
+				return "good"
+			default:
+				return "default"
+			}
+		},
+	}
+}
+
+func webhookFields(msg *loggo.Message) []WebhookField {
+	fields := make([]WebhookField, 0, len(msg.Fields))
+	for name, value := range msg.Fields {
+		fields = append(fields, WebhookField{Name: name, Value: fmt.Sprint(value)})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
@@ -0,0 +1,139 @@
+package appenders
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/claudetech/loggo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("WebhookAppender", func() {
+	var endpoint *stubEndpoint
+	var server *httptest.Server
+	var logger *loggo.Logger
+	var ctx context.Context
+
+	BeforeEach(func() {
+		endpoint = &stubEndpoint{}
+		server = httptest.NewServer(endpoint)
+		logger = loggo.New("webhook")
+		logger.SetNowFunc(func() time.Time { return time.Unix(10, 0).UTC() })
+		ctx = loggo.WithFields(context.Background(), map[string]interface{}{"tenant": "acme", "id": 42})
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+		server.Close()
+	})
+
+	body := func(i int) map[string]interface{} {
+		var payload map[string]interface{}
+		Eventually(endpoint.requests).Should(BeNumerically(">", i))
+		Expect(json.Unmarshal(endpoint.body(i), &payload)).To(Succeed())
+		return payload
+	}
+
+	It("should render the body with the template", func() {
+		appender, err := NewWebhookAppender(WebhookOptions{
+			URL:      server.URL,
+			Template: `{"text": {{json (print .Content)}}, "env": {{json (option "env")}}, "fields": {{json (fields .)}}}`,
+			Options:  map[string]string{"env": "prod"},
+			Headers:  map[string]string{"X-Key": "secret"},
+		})
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logger.ErrorCtx(ctx, `quote " and <tag>`)
+		Eventually(endpoint.requests).Should(Equal(1))
+		Expect(body(0)).To(Equal(map[string]interface{}{
+			"text": `quote " and <tag>`,
+			"env":  "prod",
+			"fields": []interface{}{
+				map[string]interface{}{"Name": "id", "Value": "42"},
+				map[string]interface{}{"Name": "tenant", "Value": "acme"},
+			},
+		}))
+		Expect(endpoint.header(0).Get("X-Key")).To(Equal("secret"))
+		Expect(endpoint.header(0).Get("Content-Type")).To(Equal("application/json"))
+	})
+
+	It("should not post from the logging goroutine", func() {
+		release := make(chan struct{})
+		blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer blocking.Close()
+		appender, err := NewTeamsAppender(blocking.URL)
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logged := make(chan struct{})
+		go func() {
+			logger.Error("foo")
+			logger.Error("bar")
+			close(logged)
+		}()
+		Eventually(logged).Should(BeClosed())
+		close(release)
+		Expect(appender.Close()).To(Succeed())
+	})
+
+	It("should reject invalid templates", func() {
+		_, err := NewWebhookAppender(WebhookOptions{URL: server.URL, Template: "{{.Content"})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should post Teams adaptive cards", func() {
+		appender, err := NewTeamsAppender(server.URL)
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logger.WarningCtx(ctx, "foo")
+		card := body(0)["attachments"].([]interface{})[0].(map[string]interface{})["content"].(map[string]interface{})
+		Expect(card["type"]).To(Equal("AdaptiveCard"))
+		blocks := card["body"].([]interface{})
+		Expect(blocks[0]).To(HaveKeyWithValue("text", "WARNING webhook"))
+		Expect(blocks[0]).To(HaveKeyWithValue("color", "warning"))
+		Expect(blocks[1]).To(HaveKeyWithValue("text", "foo"))
+		Expect(blocks[2].(map[string]interface{})["facts"]).To(HaveLen(3))
+	})
+
+	It("should post Discord embeds", func() {
+		appender, err := NewDiscordAppender(server.URL, "loggo")
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logger.ErrorCtx(ctx, "foo")
+		payload := body(0)
+		Expect(payload["username"]).To(Equal("loggo"))
+		embed := payload["embeds"].([]interface{})[0].(map[string]interface{})
+		Expect(embed["title"]).To(Equal("ERROR webhook"))
+		Expect(embed["description"]).To(Equal("foo"))
+		Expect(embed["color"]).To(Equal(float64(0xe01e5a)))
+		Expect(embed["timestamp"]).To(Equal("1970-01-01T00:00:10Z"))
+		Expect(embed["fields"]).To(HaveLen(2))
+
+		appender, err = NewDiscordAppender(server.URL, "")
+		Expect(err).To(BeNil())
+		other := loggo.New("other")
+		defer other.Destroy()
+		other.AddAppender(appender, loggo.EmptyFlag)
+		other.Info("bar")
+		Expect(body(1)).NotTo(HaveKey("username"))
+	})
+
+	It("should post Mattermost attachments", func() {
+		appender, err := NewMattermostAppender(server.URL, "loggo", "alerts")
+		Expect(err).To(BeNil())
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logger.Info("foo")
+		payload := body(0)
+		Expect(payload["username"]).To(Equal("loggo"))
+		Expect(payload["channel"]).To(Equal("alerts"))
+		attachment := payload["attachments"].([]interface{})[0].(map[string]interface{})
+		Expect(attachment["color"]).To(Equal(slackColor(loggo.Info)))
+		Expect(attachment["text"]).To(Equal("foo"))
+		Expect(attachment["fields"]).To(BeEmpty())
+		Expect(attachment["ts"]).To(Equal(float64(10)))
+	})
+})
@@ -0,0 +1,112 @@
+package loggo
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// ContextExtractor retrieves a single value from a context.
+// The boolean should be false when the context does not hold the value
+type ContextExtractor func(ctx context.Context) (interface{}, bool)
+
+type namedExtractor struct {
+	name      string
+	extractor ContextExtractor
+}
+
+var (
+	extractors     []namedExtractor
+	extractorsLock sync.RWMutex
+)
+
+type loggerContextKey struct{}
+
+type fieldsContextKey struct{}
+
+// RegisterContextExtractor registers an extractor whose value will be
+// stored in Message.Fields under the given name for every message
+// logged with a context.
+// Registering an extractor with an existing name replaces it.
+func RegisterContextExtractor(name string, extractor ContextExtractor) {
+	extractorsLock.Lock()
+	defer extractorsLock.Unlock()
+	for i, e := range extractors {
+		if e.name == name {
+			extractors[i].extractor = extractor
+			return
+		}
+	}
+	extractors = append(extractors, namedExtractor{name: name, extractor: extractor})
+	sort.Slice(extractors, func(i, j int) bool { return extractors[i].name < extractors[j].name })
+}
+
+// UnregisterContextExtractor removes the extractor with the given name
+func UnregisterContextExtractor(name string) {
+	extractorsLock.Lock()
+	defer extractorsLock.Unlock()
+	for i, e := range extractors {
+		if e.name == name {
+			extractors = append(extractors[:i], extractors[i+1:]...)
+			return
+		}
+	}
+}
+
+// ContextValueExtractor returns an extractor retrieving
+// the value stored in the context with the given key
+func ContextValueExtractor(key interface{}) ContextExtractor {
+	return func(ctx context.Context) (interface{}, bool) {
+		value := ctx.Value(key)
+		return value, value != nil
+	}
+}
+
+// WithFields returns a copy of ctx holding the given fields,
+// which are added to the fields of the messages logged with the context,
+// in addition to the fields held by ctx
+func WithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	parent, _ := ctx.Value(fieldsContextKey{}).(map[string]interface{})
+	merged := make(map[string]interface{}, len(parent)+len(fields))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsContextKey{}, merged)
+}
+
+func extractContextFields(ctx context.Context) map[string]interface{} {
+	extractorsLock.RLock()
+	defer extractorsLock.RUnlock()
+	ctxFields, _ := ctx.Value(fieldsContextKey{}).(map[string]interface{})
+	if len(extractors) == 0 && len(ctxFields) == 0 {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(extractors)+len(ctxFields))
+	for k, v := range ctxFields {
+		fields[k] = v
+	}
+	for _, e := range extractors {
+		if value, ok := e.extractor(ctx); ok {
+			fields[e.name] = value
+		}
+	}
+	return fields
+}
+
+// NewContext returns a copy of ctx holding the given logger
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext retrieves the logger stored in ctx with NewContext.
+// Returns nil if the context holds no logger
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return nil
+	}
+	logger, _ := ctx.Value(loggerContextKey{}).(*Logger)
+	return logger
+}
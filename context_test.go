@@ -0,0 +1,75 @@
+package loggo
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type requestIDKey struct{}
+
+var _ = Describe("Context", func() {
+	var logger *Logger
+	var appender *dummyAppender
+	var ctx context.Context
+
+	BeforeEach(func() {
+		logger = New("ctx")
+		appender = &dummyAppender{}
+		logger.AddAppender(appender, 0)
+		RegisterContextExtractor("request_id", ContextValueExtractor(requestIDKey{}))
+		ctx = context.WithValue(context.Background(), requestIDKey{}, "abc")
+	})
+
+	AfterEach(func() {
+		UnregisterContextExtractor("request_id")
+		logger.Destroy()
+	})
+
+	It("should attach extracted values to the message", func() {
+		logger.SetFormat(`{{.Field "request_id"}} {{.Content}}`)
+		logger.InfoCtx(ctx, "foo")
+		Expect(appender.str).To(Equal("abc foo\n"))
+	})
+
+	It("should work with format", func() {
+		logger.SetFormat(`{{.Fields.request_id}} {{.Content}}`)
+		logger.InfofCtx(ctx, "%d", 42)
+		Expect(appender.str).To(Equal("abc 42\n"))
+	})
+
+	It("should ignore missing values", func() {
+		msg := logger.makeMessage(context.Background(), Info, "", "foo", nil, 2)
+		Expect(msg.Fields).NotTo(HaveKey("request_id"))
+		Expect(msg.Field("request_id")).To(BeNil())
+	})
+
+	It("should not extract values without context", func() {
+		msg := logger.makeMessage(nil, Info, "", "foo", nil, 2)
+		Expect(msg.Fields).To(BeNil())
+		Expect(msg.Context).To(BeNil())
+	})
+
+	It("should make values available to filters", func() {
+		filtered := &dummyAppender{}
+		logger.AddAppenderWithFilter(filtered, FilterFunc(func(msg *Message) bool {
+			return msg.Field("request_id") == "abc"
+		}), 0)
+		logger.Info("foo")
+		logger.InfoCtx(ctx, "bar")
+		Expect(filtered.str).NotTo(ContainSubstring("foo"))
+		Expect(filtered.str).To(ContainSubstring("bar"))
+	})
+
+	It("should attach fields stored in the context", func() {
+		ctx = WithFields(ctx, map[string]interface{}{"tenant": "acme", "shard": 1})
+		ctx = WithFields(ctx, map[string]interface{}{"shard": 2})
+		msg := logger.makeMessage(ctx, Info, "", "foo", nil, 2)
+		Expect(msg.Fields).To(Equal(map[string]interface{}{"tenant": "acme", "shard": 2, "request_id": "abc"}))
+	})
+
+	It("should store and retrieve loggers", func() {
+		Expect(FromContext(context.Background())).To(BeNil())
+		Expect(FromContext(NewContext(ctx, logger))).To(Equal(logger))
+	})
+})
@@ -0,0 +1,180 @@
+package loggo
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Flags to modify the behavior of the dedup appender
+const (
+	DedupConsecutive = 1 << iota
+)
+
+// Patterns which can be passed to NewDedupAppender to ignore
+// variable parts of the messages
+var (
+	UUIDPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	NumberPattern = regexp.MustCompile(`[0-9]+(\.[0-9]+)?`)
+)
+
+const (
+	dedupSummaryFormat = "last message repeated %d times"
+	// like syslogd, ongoing bursts are reported every 30s by default
+	defaultDedupReportInterval = 30 * time.Second
+)
+
+type dedupKey struct {
+	name    string
+	level   Level
+	content string
+}
+
+type dedupEntry struct {
+	key      dedupKey
+	lastMsg  *Message
+	lastSeen time.Time
+	reported time.Time
+	repeated int
+	timer    *time.Timer
+}
+
+// DedupAppender wraps an appender to suppress duplicate messages.
+// Messages with the same logger name, level and content are counted
+// instead of being forwarded, and a single "last message repeated N times"
+// message is sent when the burst ends, or periodically while it goes on.
+type DedupAppender struct {
+	appender       Appender
+	window         time.Duration
+	flags          int
+	ignore         []*regexp.Regexp
+	reportCount    int
+	reportInterval time.Duration
+	nowFunc        func() time.Time
+	entries        map[dedupKey]*dedupEntry
+	last           *dedupEntry
+	lock           sync.Mutex
+}
+
+// NewDedupAppender returns an appender suppressing the duplicates of the messages
+// passed to the given appender.
+// A burst ends when no duplicate has been received for window.
+// With the DedupConsecutive flag, only consecutive messages are considered duplicates,
+// and a window of 0 means that bursts end only when a different message is received.
+// The parts of the contents matching the ignore patterns, such as NumberPattern
+// or UUIDPattern, are ignored when comparing messages, and are applied in order.
+func NewDedupAppender(appender Appender, window time.Duration, flags int, ignore ...*regexp.Regexp) *DedupAppender {
+	if window <= 0 {
+		flags |= DedupConsecutive
+	}
+	return &DedupAppender{
+		appender:       appender,
+		window:         window,
+		flags:          flags,
+		ignore:         ignore,
+		reportInterval: defaultDedupReportInterval,
+		nowFunc:        time.Now,
+		entries:        make(map[dedupKey]*dedupEntry),
+	}
+}
+
+// ReportEvery sets when the duplicates of a burst which has not ended yet are reported:
+// every count duplicates, and when the previous report is older than interval.
+// Zero disables the corresponding limit. Bursts are reported every 30s by default.
+func (d *DedupAppender) ReportEvery(count int, interval time.Duration) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.reportCount = count
+	d.reportInterval = interval
+}
+
+func (d *DedupAppender) makeKey(msg *Message) dedupKey {
+	content := fmt.Sprint(msg.Content)
+	for _, pattern := range d.ignore {
+		content = pattern.ReplaceAllString(content, "#")
+	}
+	return dedupKey{name: msg.Name, level: msg.Level, content: content}
+}
+
+// Append forwards the message unless it is a duplicate
+func (d *DedupAppender) Append(msg *Message) {
+	key := d.makeKey(msg)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := d.nowFunc()
+
+	entry, ok := d.entries[key]
+	if ok && (d.window <= 0 || now.Sub(entry.lastSeen) < d.window) {
+		entry.repeated++
+		entry.lastMsg = msg
+		entry.lastSeen = now
+		if entry.timer != nil {
+			entry.timer.Reset(d.window)
+		}
+		if (d.reportCount > 0 && entry.repeated >= d.reportCount) ||
+			(d.reportInterval > 0 && now.Sub(entry.reported) >= d.reportInterval) {
+			d.report(entry, now)
+		}
+		return
+	}
+	if ok {
+		d.flush(entry)
+	}
+	if d.flags&DedupConsecutive != 0 && d.last != nil {
+		d.flush(d.last)
+	}
+	entry = &dedupEntry{key: key, lastMsg: msg, lastSeen: now, reported: now}
+	if d.window > 0 {
+		entry.timer = time.AfterFunc(d.window, func() {
+			d.lock.Lock()
+			defer d.lock.Unlock()
+			if d.entries[entry.key] == entry {
+				d.flush(entry)
+			}
+		})
+	}
+	d.entries[key] = entry
+	d.last = entry
+	d.appender.Append(msg)
+}
+
+// flush removes the entry and reports its duplicates, if any
+func (d *DedupAppender) flush(entry *dedupEntry) {
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	delete(d.entries, entry.key)
+	if d.last == entry {
+		d.last = nil
+	}
+	d.report(entry, d.nowFunc())
+}
+
+// report sends the number of duplicates received since the last report, if any
+func (d *DedupAppender) report(entry *dedupEntry, now time.Time) {
+	entry.reported = now
+	if entry.repeated == 0 {
+		return
+	}
+	summary := *entry.lastMsg
+	summary.ContentFormat = dedupSummaryFormat
+	summary.Content = fmt.Sprintf(dedupSummaryFormat, entry.repeated)
+	entry.repeated = 0
+	d.appender.Append(&summary)
+}
+
+// Close reports the pending duplicates and closes the wrapped appender
+// if it implements io.Closer
+func (d *DedupAppender) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, entry := range d.entries {
+		d.flush(entry)
+	}
+	if closer, ok := d.appender.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package loggo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"text/template"
+	"time"
+)
+
+var _ = Describe("DedupAppender", func() {
+	var appender *dummyAppender
+	var dedup *DedupAppender
+	var now time.Time
+
+	tpl, _ := template.New("foo").Parse("{{.Level}} {{.Content}}\n")
+	makeMsg := func(level Level, content string) *Message {
+		return &Message{Name: "foo", Level: level, Content: content, tpl: tpl}
+	}
+
+	BeforeEach(func() {
+		now = dummyTime()
+		appender = &dummyAppender{}
+		dedup = NewDedupAppender(appender, 0, DedupConsecutive)
+		dedup.nowFunc = func() time.Time { return now }
+	})
+
+	It("should suppress consecutive duplicates", func() {
+		dedup.Append(makeMsg(Error, "foo"))
+		dedup.Append(makeMsg(Error, "foo"))
+		dedup.Append(makeMsg(Error, "foo"))
+		Expect(appender.str).To(Equal("ERROR foo\n"))
+		dedup.Append(makeMsg(Info, "foo"))
+		Expect(appender.str).To(Equal("ERROR foo\nERROR last message repeated 2 times\nINFO foo\n"))
+	})
+
+	It("should not report single messages", func() {
+		dedup.Append(makeMsg(Error, "foo"))
+		dedup.Append(makeMsg(Error, "bar"))
+		Expect(appender.str).To(Equal("ERROR foo\nERROR bar\n"))
+	})
+
+	It("should ignore variable parts", func() {
+		dedup = NewDedupAppender(appender, 0, DedupConsecutive, UUIDPattern, NumberPattern)
+		dedup.Append(makeMsg(Error, "request 4f0c7e3a-1b2d-4c5e-8f9a-0b1c2d3e4f5a failed after 12ms"))
+		dedup.Append(makeMsg(Error, "request 9a8b7c6d-1b2d-4c5e-8f9a-0b1c2d3e4f5a failed after 3.5ms"))
+		Expect(dedup.Close()).To(BeNil())
+		Expect(appender.str).To(HaveSuffix("ERROR last message repeated 1 times\n"))
+	})
+
+	It("should detect windowed duplicates", func() {
+		dedup = NewDedupAppender(appender, time.Hour, 0)
+		dedup.nowFunc = func() time.Time { return now }
+		dedup.Append(makeMsg(Error, "foo"))
+		dedup.Append(makeMsg(Error, "bar"))
+		dedup.Append(makeMsg(Error, "foo"))
+		dedup.Append(makeMsg(Error, "bar"))
+		Expect(appender.str).To(Equal("ERROR foo\nERROR bar\n"))
+		now = now.Add(time.Hour)
+		dedup.Append(makeMsg(Error, "foo"))
+		Expect(appender.str).To(Equal("ERROR foo\nERROR bar\nERROR last message repeated 1 times\nERROR foo\n"))
+		Expect(dedup.Close()).To(BeNil())
+	})
+
+	It("should report ongoing bursts periodically", func() {
+		dedup.Append(makeMsg(Error, "foo"))
+		for i := 0; i < 5; i++ {
+			now = now.Add(10 * time.Second)
+			dedup.Append(makeMsg(Error, "foo"))
+		}
+		Expect(appender.str).To(Equal("ERROR foo\nERROR last message repeated 3 times\n"))
+		dedup.ReportEvery(3, 0)
+		dedup.Append(makeMsg(Error, "foo"))
+		Expect(appender.str).To(Equal("ERROR foo\nERROR last message repeated 3 times\nERROR last message repeated 3 times\n"))
+		dedup.Append(makeMsg(Info, "foo"))
+		Expect(appender.str).To(HaveSuffix("ERROR last message repeated 3 times\nINFO foo\n"))
+	})
+
+	It("should report duplicates when the burst ends", func() {
+		dedup = NewDedupAppender(appender, 10*time.Millisecond, DedupConsecutive)
+		dedup.Append(makeMsg(Error, "foo"))
+		dedup.Append(makeMsg(Error, "foo"))
+		Eventually(func() string {
+			dedup.lock.Lock()
+			defer dedup.lock.Unlock()
+			return appender.str
+		}).Should(Equal("ERROR foo\nERROR last message repeated 1 times\n"))
+	})
+})
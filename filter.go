@@ -1,7 +1,15 @@
 package loggo
 
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
 // Filter interface is used to check if the log
-// should be written by then appender
+// should be written by then appender.
+// When used with a TransientAppender, ShouldLog must not keep msg once it returns
 type Filter interface {
 	ShouldLog(msg *Message) bool
 }
@@ -15,7 +23,7 @@ type MinLogLevelFilter struct {
 // ShouldLog returns true if msg.Level is greater or equal to
 // the filter MinLevel
 func (f *MinLogLevelFilter) ShouldLog(msg *Message) bool {
-	return msg.Level >= f.MinLevel
+	return msg.Level.AtLeast(f.MinLevel)
 }
 
 // MaxLogLevelFilter filters all messages
@@ -27,5 +35,161 @@ type MaxLogLevelFilter struct {
 // ShouldLog returns true if msg.Level is lower or equal to
 // the filter MaxLevel
 func (f *MaxLogLevelFilter) ShouldLog(msg *Message) bool {
-	return msg.Level <= f.MaxLevel
+	return f.MaxLevel.AtLeast(msg.Level)
+}
+
+// FilterFunc is an adapter to use a function as a Filter
+type FilterFunc func(msg *Message) bool
+
+// ShouldLog returns f(msg)
+func (f FilterFunc) ShouldLog(msg *Message) bool {
+	return f(msg)
+}
+
+// AndFilter accepts messages accepted by all its filters
+type AndFilter struct {
+	Filters []Filter
+}
+
+// ShouldLog returns true if all the filters return true
+func (f *AndFilter) ShouldLog(msg *Message) bool {
+	for _, filter := range f.Filters {
+		if !filter.ShouldLog(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrFilter accepts messages accepted by at least one of its filters
+type OrFilter struct {
+	Filters []Filter
+}
+
+// ShouldLog returns true if any of the filters returns true
+func (f *OrFilter) ShouldLog(msg *Message) bool {
+	for _, filter := range f.Filters {
+		if filter.ShouldLog(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotFilter accepts messages rejected by its filter
+type NotFilter struct {
+	Filter Filter
+}
+
+// ShouldLog returns the opposite of the filter
+func (f *NotFilter) ShouldLog(msg *Message) bool {
+	return !f.Filter.ShouldLog(msg)
+}
+
+// NewAndFilter returns a filter accepting messages accepted by all the given filters
+func NewAndFilter(filters ...Filter) Filter {
+	return &AndFilter{Filters: filters}
+}
+
+// NewOrFilter returns a filter accepting messages accepted by any of the given filters
+func NewOrFilter(filters ...Filter) Filter {
+	return &OrFilter{Filters: filters}
+}
+
+// NewNotFilter returns a filter accepting messages rejected by the given filter
+func NewNotFilter(filter Filter) Filter {
+	return &NotFilter{Filter: filter}
+}
+
+// LevelRangeFilter filters all messages
+// with a log level outside of [MinLevel, MaxLevel]
+type LevelRangeFilter struct {
+	MinLevel Level
+	MaxLevel Level
+}
+
+// ShouldLog returns true if msg.Level is between MinLevel and MaxLevel
+func (f *LevelRangeFilter) ShouldLog(msg *Message) bool {
+	return msg.Level.AtLeast(f.MinLevel) && f.MaxLevel.AtLeast(msg.Level)
+}
+
+// NameGlobFilter filters all messages whose logger name
+// does not match Pattern, using path.Match syntax
+type NameGlobFilter struct {
+	Pattern string
+}
+
+// ShouldLog returns true if the logger name matches Pattern
+func (f *NameGlobFilter) ShouldLog(msg *Message) bool {
+	matched, _ := path.Match(f.Pattern, msg.Name)
+	return matched
+}
+
+// NamePrefixFilter filters all messages whose logger name
+// does not start with Prefix
+type NamePrefixFilter struct {
+	Prefix string
+}
+
+// ShouldLog returns true if the logger name starts with Prefix
+func (f *NamePrefixFilter) ShouldLog(msg *Message) bool {
+	return strings.HasPrefix(msg.Name, f.Prefix)
+}
+
+// ContentRegexpFilter filters all messages whose content
+// does not match Regexp
+type ContentRegexpFilter struct {
+	Regexp *regexp.Regexp
+}
+
+// ShouldLog returns true if the content matches Regexp
+func (f *ContentRegexpFilter) ShouldLog(msg *Message) bool {
+	msg.ResolveContent()
+	return f.Regexp.MatchString(fmt.Sprint(msg.Content))
+}
+
+// CallerFileFilter filters all messages whose caller file
+// does not match Pattern, using path.Match syntax.
+// The pattern is matched against both the full path and the base name of the file.
+// Caller information must be enabled on the logger.
+type CallerFileFilter struct {
+	Pattern string
+}
+
+// ShouldLog returns true if the caller file matches Pattern
+func (f *CallerFileFilter) ShouldLog(msg *Message) bool {
+	if msg.File == "" {
+		return false
+	}
+	if matched, _ := path.Match(f.Pattern, msg.File); matched {
+		return true
+	}
+	matched, _ := path.Match(f.Pattern, path.Base(msg.File))
+	return matched
+}
+
+// CallerPackageFilter filters all messages not logged from Package
+// or one of its sub packages.
+// Caller information must be enabled on the logger.
+type CallerPackageFilter struct {
+	Package string
+}
+
+// ShouldLog returns true if the caller package is Package or one of its sub packages
+func (f *CallerPackageFilter) ShouldLog(msg *Message) bool {
+	pkg := msg.Package()
+	return pkg != "" && (pkg == f.Package || strings.HasPrefix(pkg, f.Package+"/"))
+}
+
+// FieldFilter filters all messages whose field Name
+// is not equal to Value. Values are compared using their string representation.
+type FieldFilter struct {
+	Name  string
+	Value interface{}
+}
+
+// ShouldLog returns true if the field Name equals Value
+func (f *FieldFilter) ShouldLog(msg *Message) bool {
+	value, ok := msg.Fields[f.Name]
+	return ok && fmt.Sprint(value) == fmt.Sprint(f.Value)
 }
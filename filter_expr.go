@@ -0,0 +1,289 @@
+package loggo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseFilter compiles a filter expression into a Filter.
+//
+// An expression is made of comparisons combined with &&, || and !,
+// and grouped with parentheses, for example
+//
+//	level >= warning && name ~ "db.*" && !content ~ "timeout"
+//
+// The left side of a comparison is one of level, name, content, file,
+// func, package or field.<name>. level supports ==, !=, <, <=, > and >=
+// with a level name. The other ones support == and != for equality,
+// and ~ and !~ to match a regular expression.
+// Values are either double quoted strings or bare words.
+func ParseFilter(expr string) (Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.value, tok.pos)
+	}
+	return filter, nil
+}
+
+// MustParseFilter is like ParseFilter but panics if the expression is invalid
+func MustParseFilter(expr string) Filter {
+	filter, err := ParseFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type filterToken struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "~", "!"}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	i := 0
+	for i < len(expr) {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: tokenLParen, value: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: tokenRParen, value: ")", pos: i})
+			i++
+		case c == '"':
+			str, err := strconv.QuotedPrefix(expr[i:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			value, _ := strconv.Unquote(str)
+			tokens = append(tokens, filterToken{kind: tokenString, value: value, pos: i})
+			i += len(str)
+		case isFilterIdentRune(c):
+			start := i
+			for i < len(expr) && isFilterIdentRune(rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: tokenIdent, value: expr[start:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range filterOperators {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, filterToken{kind: tokenOp, value: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, filterToken{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+func isFilterIdentRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_.-/*", c)
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) accept(kind tokenKind, value string) bool {
+	if tok := p.peek(); tok.kind == kind && tok.value == value {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	filter, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{filter}
+	for p.accept(tokenOp, "||") {
+		if filter, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return NewOrFilter(filters...), nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	filter, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{filter}
+	for p.accept(tokenOp, "&&") {
+		if filter, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return NewAndFilter(filters...), nil
+}
+
+func (p *filterParser) parseUnary() (Filter, error) {
+	if p.accept(tokenOp, "!") {
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NewNotFilter(filter), nil
+	}
+	if p.accept(tokenLParen, "(") {
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(tokenRParen, ")") {
+			tok := p.peek()
+			return nil, fmt.Errorf("expected ) at position %d", tok.pos)
+		}
+		return filter, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (Filter, error) {
+	ident := p.next()
+	if ident.kind != tokenIdent {
+		return nil, fmt.Errorf("expected identifier at position %d", ident.pos)
+	}
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, fmt.Errorf("expected operator at position %d", op.pos)
+	}
+	value := p.next()
+	if value.kind != tokenIdent && value.kind != tokenString {
+		return nil, fmt.Errorf("expected value at position %d", value.pos)
+	}
+	if ident.value == "level" {
+		return makeLevelComparison(op, value)
+	}
+	getter, err := filterGetter(ident)
+	if err != nil {
+		return nil, err
+	}
+	switch op.value {
+	case "==", "!=":
+		equal := op.value == "=="
+		return FilterFunc(func(msg *Message) bool {
+			return (getter(msg) == value.value) == equal
+		}), nil
+	case "~", "!~":
+		re, err := regexp.Compile(value.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp at position %d: %s", value.pos, err)
+		}
+		match := op.value == "~"
+		return FilterFunc(func(msg *Message) bool {
+			return re.MatchString(getter(msg)) == match
+		}), nil
+	default:
+		return nil, fmt.Errorf("operator %s not supported for %s at position %d", op.value, ident.value, op.pos)
+	}
+}
+
+func filterGetter(ident filterToken) (func(*Message) string, error) {
+	switch ident.value {
+	case "name":
+		return func(msg *Message) string { return msg.Name }, nil
+	case "content":
+		return func(msg *Message) string {
+			msg.ResolveContent()
+			return fmt.Sprint(msg.Content)
+		}, nil
+	case "file":
+		return func(msg *Message) string { return msg.File }, nil
+	case "func":
+		return func(msg *Message) string { return msg.FuncName }, nil
+	case "package":
+		return func(msg *Message) string { return msg.Package() }, nil
+	}
+	if strings.HasPrefix(ident.value, "field.") && len(ident.value) > len("field.") {
+		name := strings.TrimPrefix(ident.value, "field.")
+		return func(msg *Message) string {
+			if value, ok := msg.Fields[name]; ok {
+				return fmt.Sprint(value)
+			}
+			return ""
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown identifier %q at position %d", ident.value, ident.pos)
+}
+
+func makeLevelComparison(op filterToken, value filterToken) (Filter, error) {
+	level, err := ParseLevel(value.value)
+	if err != nil {
+		return nil, fmt.Errorf("%s at position %d", err, value.pos)
+	}
+	switch op.value {
+	case "==":
+		return &LevelRangeFilter{MinLevel: level, MaxLevel: level}, nil
+	case "!=":
+		return NewNotFilter(&LevelRangeFilter{MinLevel: level, MaxLevel: level}), nil
+	case "<":
+		return NewNotFilter(&MinLogLevelFilter{MinLevel: level}), nil
+	case "<=":
+		return &MaxLogLevelFilter{MaxLevel: level}, nil
+	case ">":
+		return NewNotFilter(&MaxLogLevelFilter{MaxLevel: level}), nil
+	case ">=":
+		return &MinLogLevelFilter{MinLevel: level}, nil
+	default:
+		return nil, fmt.Errorf("operator %s not supported for level at position %d", op.value, op.pos)
+	}
+}
@@ -0,0 +1,73 @@
+package loggo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseFilter", func() {
+	var msg *Message
+
+	BeforeEach(func() {
+		msg = &Message{
+			Name:     "db.users",
+			Level:    Error,
+			Content:  "connection refused",
+			File:     "/src/app/db.go",
+			FuncName: "example.com/app/db.Connect",
+			Fields:   map[string]interface{}{"tenant": "acme"},
+		}
+	})
+
+	shouldLog := func(expr string) bool {
+		filter, err := ParseFilter(expr)
+		Expect(err).To(BeNil())
+		return filter.ShouldLog(msg)
+	}
+
+	It("should compare levels", func() {
+		Expect(shouldLog("level >= warning")).To(BeTrue())
+		Expect(shouldLog("level > error")).To(BeFalse())
+		Expect(shouldLog("level<=error")).To(BeTrue())
+		Expect(shouldLog("level < error")).To(BeFalse())
+		Expect(shouldLog("level == ERROR")).To(BeTrue())
+		Expect(shouldLog("level != error")).To(BeFalse())
+	})
+
+	It("should compare strings", func() {
+		Expect(shouldLog(`name == "db.users"`)).To(BeTrue())
+		Expect(shouldLog(`name != db.users`)).To(BeFalse())
+		Expect(shouldLog(`name ~ "^db\\."`)).To(BeTrue())
+		Expect(shouldLog(`content !~ "refused"`)).To(BeFalse())
+		Expect(shouldLog(`file ~ "db.go$"`)).To(BeTrue())
+		Expect(shouldLog(`func == "example.com/app/db.Connect"`)).To(BeTrue())
+		Expect(shouldLog(`package == "example.com/app/db"`)).To(BeTrue())
+		Expect(shouldLog(`field.tenant == acme`)).To(BeTrue())
+		Expect(shouldLog(`field.missing == acme`)).To(BeFalse())
+	})
+
+	It("should combine expressions", func() {
+		Expect(shouldLog(`level >= warning && name ~ "db.*" && !content ~ "timeout"`)).To(BeTrue())
+		Expect(shouldLog(`level >= fatal || content ~ "timeout"`)).To(BeFalse())
+		Expect(shouldLog(`!(level >= fatal || content ~ "timeout")`)).To(BeTrue())
+		Expect(shouldLog(`level == info || level == error && name == db.users`)).To(BeTrue())
+	})
+
+	It("should return errors", func() {
+		for _, expr := range []string{
+			"",
+			"level >= foo",
+			"level ~ info",
+			"unknown == foo",
+			"name ==",
+			`name == "foo`,
+			"(level >= info",
+			"level >= info)",
+			"name ~ \"(\"",
+			"name # foo",
+		} {
+			_, err := ParseFilter(expr)
+			Expect(err).NotTo(BeNil(), expr)
+		}
+	})
+})
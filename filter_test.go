@@ -3,6 +3,7 @@ package loggo
 import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"regexp"
 	"time"
 )
 
@@ -48,4 +49,80 @@ var _ = Describe("Filter", func() {
 			Expect(filter.ShouldLog(msg)).To(BeFalse())
 		})
 	})
+	Describe("combinators", func() {
+		yes := FilterFunc(func(*Message) bool { return true })
+		no := FilterFunc(func(*Message) bool { return false })
+
+		It("should combine filters", func() {
+			Expect(NewAndFilter(yes, yes).ShouldLog(msg)).To(BeTrue())
+			Expect(NewAndFilter(yes, no).ShouldLog(msg)).To(BeFalse())
+			Expect(NewOrFilter(no, yes).ShouldLog(msg)).To(BeTrue())
+			Expect(NewOrFilter(no, no).ShouldLog(msg)).To(BeFalse())
+			Expect(NewNotFilter(no).ShouldLog(msg)).To(BeTrue())
+			Expect(NewAndFilter().ShouldLog(msg)).To(BeTrue())
+			Expect(NewOrFilter().ShouldLog(msg)).To(BeFalse())
+		})
+	})
+
+	Describe("LevelRangeFilter", func() {
+		It("should return true when level is in range", func() {
+			filter = &LevelRangeFilter{MinLevel: Debug, MaxLevel: Info}
+			Expect(filter.ShouldLog(msg)).To(BeTrue())
+			msg.Level = Trace
+			Expect(filter.ShouldLog(msg)).To(BeFalse())
+			msg.Level = Warning
+			Expect(filter.ShouldLog(msg)).To(BeFalse())
+		})
+	})
+
+	Describe("name filters", func() {
+		It("should match globs", func() {
+			msg.Name = "db.users"
+			Expect((&NameGlobFilter{Pattern: "db.*"}).ShouldLog(msg)).To(BeTrue())
+			Expect((&NameGlobFilter{Pattern: "http.*"}).ShouldLog(msg)).To(BeFalse())
+		})
+
+		It("should match prefixes", func() {
+			msg.Name = "db.users"
+			Expect((&NamePrefixFilter{Prefix: "db."}).ShouldLog(msg)).To(BeTrue())
+			Expect((&NamePrefixFilter{Prefix: "users"}).ShouldLog(msg)).To(BeFalse())
+		})
+	})
+
+	Describe("ContentRegexpFilter", func() {
+		It("should match the content", func() {
+			Expect((&ContentRegexpFilter{Regexp: regexp.MustCompile("^b")}).ShouldLog(msg)).To(BeTrue())
+			Expect((&ContentRegexpFilter{Regexp: regexp.MustCompile("^f")}).ShouldLog(msg)).To(BeFalse())
+		})
+	})
+
+	Describe("caller filters", func() {
+		BeforeEach(func() {
+			msg.File = "/src/github.com/foo/bar/baz.go"
+			msg.FuncName = "github.com/foo/bar.(*T).Method"
+		})
+
+		It("should match the file", func() {
+			Expect((&CallerFileFilter{Pattern: "baz.go"}).ShouldLog(msg)).To(BeTrue())
+			Expect((&CallerFileFilter{Pattern: "/src/*/foo/bar/*.go"}).ShouldLog(msg)).To(BeTrue())
+			Expect((&CallerFileFilter{Pattern: "qux.go"}).ShouldLog(msg)).To(BeFalse())
+		})
+
+		It("should match the package", func() {
+			Expect(msg.Package()).To(Equal("github.com/foo/bar"))
+			Expect((&CallerPackageFilter{Package: "github.com/foo"}).ShouldLog(msg)).To(BeTrue())
+			Expect((&CallerPackageFilter{Package: "github.com/foo/bar"}).ShouldLog(msg)).To(BeTrue())
+			Expect((&CallerPackageFilter{Package: "github.com/foo/b"}).ShouldLog(msg)).To(BeFalse())
+		})
+	})
+
+	Describe("FieldFilter", func() {
+		It("should compare fields", func() {
+			msg.Fields = map[string]interface{}{"tenant": "acme", "shard": 2}
+			Expect((&FieldFilter{Name: "tenant", Value: "acme"}).ShouldLog(msg)).To(BeTrue())
+			Expect((&FieldFilter{Name: "shard", Value: "2"}).ShouldLog(msg)).To(BeTrue())
+			Expect((&FieldFilter{Name: "tenant", Value: "other"}).ShouldLog(msg)).To(BeFalse())
+			Expect((&FieldFilter{Name: "missing", Value: ""}).ShouldLog(msg)).To(BeFalse())
+		})
+	})
 })
@@ -0,0 +1,148 @@
+package loggo
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const defaultMaxScopes = 1000
+
+// messageRing is a fixed capacity buffer keeping the most recent messages
+type messageRing struct {
+	messages []*Message
+	start    int
+	size     int
+}
+
+func newMessageRing(capacity int) *messageRing {
+	return &messageRing{messages: make([]*Message, capacity)}
+}
+
+func (r *messageRing) push(msg *Message) {
+	if len(r.messages) == 0 {
+		return
+	}
+	end := (r.start + r.size) % len(r.messages)
+	r.messages[end] = msg
+	if r.size < len(r.messages) {
+		r.size++
+	} else {
+		r.start = (r.start + 1) % len(r.messages)
+	}
+}
+
+// drain returns the buffered messages from the oldest to the newest and empties the ring
+func (r *messageRing) drain() []*Message {
+	result := make([]*Message, r.size)
+	for i := 0; i < r.size; i++ {
+		idx := (r.start + i) % len(r.messages)
+		result[i] = r.messages[idx]
+		r.messages[idx] = nil
+	}
+	r.start = 0
+	r.size = 0
+	return result
+}
+
+type fingersCrossedScope struct {
+	key     string
+	ring    *messageRing
+	element *list.Element
+}
+
+// FingersCrossedAppender buffers the messages below a trigger level,
+// and only forwards them to its appender when a message at or above
+// the trigger level is received.
+type FingersCrossedAppender struct {
+	appender   Appender
+	trigger    Level
+	size       int
+	scopeField string
+	maxScopes  int
+	scopes     map[string]*fingersCrossedScope
+	lru        *list.List
+	lock       sync.Mutex
+}
+
+// NewFingersCrossedAppender returns an appender keeping the last size messages
+// below the trigger level. When a message at or above the trigger level is received,
+// the buffered messages are sent to the given appender followed by the trigger message.
+func NewFingersCrossedAppender(appender Appender, trigger Level, size int) *FingersCrossedAppender {
+	return &FingersCrossedAppender{
+		appender:  appender,
+		trigger:   trigger,
+		size:      size,
+		maxScopes: defaultMaxScopes,
+		scopes:    make(map[string]*fingersCrossedScope),
+		lru:       list.New(),
+	}
+}
+
+// SetScopeField keeps a separate buffer for each value of the given message field,
+// for example a request ID extracted from the context, so that a trigger
+// only flushes the history of its own request.
+// At most maxScopes buffers are kept, the least recently used ones being dropped.
+func (f *FingersCrossedAppender) SetScopeField(name string, maxScopes int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if maxScopes <= 0 {
+		maxScopes = defaultMaxScopes
+	}
+	f.scopeField = name
+	f.maxScopes = maxScopes
+}
+
+func (f *FingersCrossedAppender) scope(msg *Message) *fingersCrossedScope {
+	key := ""
+	if f.scopeField != "" {
+		if value, ok := msg.Fields[f.scopeField]; ok {
+			key = fmt.Sprint(value)
+		}
+	}
+	if scope, ok := f.scopes[key]; ok {
+		f.lru.MoveToFront(scope.element)
+		return scope
+	}
+	scope := &fingersCrossedScope{key: key, ring: newMessageRing(f.size)}
+	scope.element = f.lru.PushFront(scope)
+	f.scopes[key] = scope
+	for f.lru.Len() > f.maxScopes {
+		oldest := f.lru.Remove(f.lru.Back()).(*fingersCrossedScope)
+		delete(f.scopes, oldest.key)
+	}
+	return scope
+}
+
+// Append buffers the message, or flushes the buffer if the message
+// level is at or above the trigger level
+func (f *FingersCrossedAppender) Append(msg *Message) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	scope := f.scope(msg)
+	if !msg.Level.AtLeast(f.trigger) {
+		scope.ring.push(msg)
+		return
+	}
+	for _, buffered := range scope.ring.drain() {
+		// the buffered message may still be used by other appenders
+		copied := *buffered
+		copied.color = msg.color
+		f.appender.Append(&copied)
+	}
+	f.appender.Append(msg)
+}
+
+// Close drops the buffered messages and closes the wrapped appender
+// if it implements io.Closer
+func (f *FingersCrossedAppender) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.scopes = make(map[string]*fingersCrossedScope)
+	f.lru.Init()
+	if closer, ok := f.appender.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
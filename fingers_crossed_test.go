@@ -0,0 +1,64 @@
+package loggo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"text/template"
+)
+
+var _ = Describe("FingersCrossedAppender", func() {
+	var appender *dummyAppender
+	var fc *FingersCrossedAppender
+
+	tpl, _ := template.New("foo").Parse("{{.Content}}\n")
+	makeMsg := func(level Level, content string, fields map[string]interface{}) *Message {
+		return &Message{Level: level, Content: content, Fields: fields, tpl: tpl}
+	}
+
+	BeforeEach(func() {
+		appender = &dummyAppender{}
+		fc = NewFingersCrossedAppender(appender, Error, 2)
+	})
+
+	It("should buffer messages below the trigger level", func() {
+		fc.Append(makeMsg(Debug, "a", nil))
+		fc.Append(makeMsg(Warning, "b", nil))
+		Expect(appender.str).To(BeEmpty())
+	})
+
+	It("should flush the last messages on trigger", func() {
+		fc.Append(makeMsg(Debug, "a", nil))
+		fc.Append(makeMsg(Debug, "b", nil))
+		fc.Append(makeMsg(Info, "c", nil))
+		fc.Append(makeMsg(Error, "d", nil))
+		Expect(appender.str).To(Equal("b\nc\nd\n"))
+		fc.Append(makeMsg(Fatal, "e", nil))
+		Expect(appender.str).To(Equal("b\nc\nd\ne\n"))
+	})
+
+	It("should not modify the buffered messages", func() {
+		buffered := makeMsg(Debug, "a", nil)
+		fc.Append(buffered)
+		trigger := makeMsg(Error, "b", nil)
+		trigger.color = true
+		fc.Append(trigger)
+		Expect(buffered.color).To(BeFalse())
+	})
+
+	It("should keep a buffer per scope", func() {
+		fc.SetScopeField("request_id", 0)
+		fc.Append(makeMsg(Debug, "a", map[string]interface{}{"request_id": 1}))
+		fc.Append(makeMsg(Debug, "b", map[string]interface{}{"request_id": 2}))
+		fc.Append(makeMsg(Debug, "c", nil))
+		fc.Append(makeMsg(Error, "d", map[string]interface{}{"request_id": 2}))
+		Expect(appender.str).To(Equal("b\nd\n"))
+	})
+
+	It("should drop the least recently used scopes", func() {
+		fc.SetScopeField("request_id", 1)
+		fc.Append(makeMsg(Debug, "a", map[string]interface{}{"request_id": 1}))
+		fc.Append(makeMsg(Debug, "b", map[string]interface{}{"request_id": 2}))
+		fc.Append(makeMsg(Error, "c", map[string]interface{}{"request_id": 1}))
+		Expect(appender.str).To(Equal("c\n"))
+	})
+})
@@ -0,0 +1,139 @@
+package loggo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// formatSegment appends a part of the formatted message to buf
+type formatSegment func(buf []byte, m *Message) []byte
+
+// compiledFormat formats messages without text/template
+// for formats only made of simple placeholders
+type compiledFormat struct {
+	segments []formatSegment
+}
+
+var formatFields = map[string]formatSegment{
+	"Name":          func(buf []byte, m *Message) []byte { return append(buf, m.Name...) },
+	"NameUp":        appendNameUp,
+	"Level":         func(buf []byte, m *Message) []byte { return append(buf, m.Level.String()...) },
+	"LevelStr":      appendLevelStr,
+	"Content":       func(buf []byte, m *Message) []byte { return appendValue(buf, m.Content) },
+	"ContentFormat": func(buf []byte, m *Message) []byte { return append(buf, m.ContentFormat...) },
+	"Time":          func(buf []byte, m *Message) []byte { return append(buf, m.Time.String()...) },
+	"TimeStr":       func(buf []byte, m *Message) []byte { return m.Time.AppendFormat(buf, m.dateFormat) },
+	"File":          func(buf []byte, m *Message) []byte { return append(buf, m.File...) },
+	"Line":          func(buf []byte, m *Message) []byte { return strconv.AppendInt(buf, int64(m.Line), 10) },
+	"FuncName":      func(buf []byte, m *Message) []byte { return append(buf, m.FuncName...) },
+	"Package":       func(buf []byte, m *Message) []byte { return append(buf, m.Package()...) },
+	"Stack":         func(buf []byte, m *Message) []byte { return append(buf, m.Stack...) },
+	"TraceID":       func(buf []byte, m *Message) []byte { return append(buf, m.TraceID()...) },
+	"SpanID":        func(buf []byte, m *Message) []byte { return append(buf, m.SpanID()...) },
+	"TraceFlags":    func(buf []byte, m *Message) []byte { return append(buf, m.TraceFlags()...) },
+}
+
+func appendNameUp(buf []byte, m *Message) []byte {
+	for i := 0; i < len(m.Name); i++ {
+		if m.Name[i] >= utf8.RuneSelf {
+			return append(buf, m.NameUp()...)
+		}
+	}
+	for i := 0; i < len(m.Name); i++ {
+		c := m.Name[i]
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+func appendLevelStr(buf []byte, m *Message) []byte {
+	str := m.Level.String()
+	buf = append(buf, str...)
+	if m.padding {
+		for i := len(str); i < levelNameWidth(); i++ {
+			buf = append(buf, ' ')
+		}
+	}
+	return buf
+}
+
+func appendValue(buf []byte, v interface{}) []byte {
+	switch value := v.(type) {
+	case string:
+		return append(buf, value...)
+	case nil:
+		// same output as text/template
+		return append(buf, "<no value>"...)
+	}
+	return fmt.Append(buf, v)
+}
+
+// compileFormat compiles the format if it only contains literal text
+// and placeholders like {{.Content}}. Returns nil otherwise, in which
+// case the format must be executed with text/template
+func compileFormat(format string) *compiledFormat {
+	compiled := &compiledFormat{}
+	for len(format) > 0 {
+		start := strings.Index(format, "{{")
+		if start < 0 {
+			compiled.addText(format)
+			break
+		}
+		compiled.addText(format[:start])
+		end := strings.Index(format[start:], "}}")
+		if end < 0 {
+			return nil
+		}
+		action := strings.TrimSpace(format[start+2 : start+end])
+		if !strings.HasPrefix(action, ".") {
+			return nil
+		}
+		segment, ok := formatFields[action[1:]]
+		if !ok {
+			return nil
+		}
+		compiled.segments = append(compiled.segments, segment)
+		format = format[start+end+2:]
+	}
+	return compiled
+}
+
+func (c *compiledFormat) addText(text string) {
+	if text != "" {
+		c.segments = append(c.segments, func(buf []byte, m *Message) []byte {
+			return append(buf, text...)
+		})
+	}
+}
+
+func (c *compiledFormat) appendTo(buf []byte, m *Message) []byte {
+	for _, segment := range c.segments {
+		buf = segment(buf, m)
+	}
+	return buf
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(buf *[]byte) {
+	// avoid keeping huge buffers in the pool
+	if cap(*buf) <= 64<<10 {
+		*buf = (*buf)[:0]
+		bufferPool.Put(buf)
+	}
+}
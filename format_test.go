@@ -0,0 +1,75 @@
+package loggo
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"text/template"
+)
+
+var _ = Describe("compiledFormat", func() {
+	var msg *Message
+
+	BeforeEach(func() {
+		msg = &Message{
+			Name:       "foo.bar",
+			Level:      Warning,
+			Content:    "foo",
+			Time:       dummyTime(),
+			File:       "/src/foo/bar.go",
+			Line:       42,
+			FuncName:   "github.com/foo/bar.Baz",
+			dateFormat: defaultDateFormat,
+			padding:    true,
+			Fields:     map[string]interface{}{TraceIDField: "abc"},
+		}
+	})
+
+	withTemplate := func(format string) string {
+		m := *msg
+		m.tpl = template.Must(template.New("foo").Parse(format))
+		return m.String()
+	}
+
+	withCompiled := func(format string) string {
+		m := *msg
+		m.format = compileFormat(format)
+		Expect(m.format).NotTo(BeNil())
+		return m.String()
+	}
+
+	It("should produce the same output as the template", func() {
+		formats := []string{
+			defaultFormat,
+			"{{.Content}}",
+			"no placeholder\n",
+			"{{ .Level }} {{.Name}} {{.File}}:{{.Line}} {{.FuncName}} {{.Package}}\n",
+			"{{.TimeStr}} {{.Time}} trace={{.TraceID}} span={{.SpanID}}",
+		}
+		for _, format := range formats {
+			Expect(withCompiled(format)).To(Equal(withTemplate(format)), format)
+		}
+	})
+
+	It("should format non string contents like the template", func() {
+		for _, content := range []interface{}{nil, 42, errors.New("failed"), []string{"a", "b"}} {
+			msg.Content = content
+			Expect(withCompiled("{{.Content}}")).To(Equal(withTemplate("{{.Content}}")))
+		}
+	})
+
+	It("should not compile formats using other template features", func() {
+		Expect(compileFormat("{{if .Stack}}{{.Stack}}{{end}}")).To(BeNil())
+		Expect(compileFormat("{{.Content | printf \"%q\"}}")).To(BeNil())
+		Expect(compileFormat("{{.Unknown}}")).To(BeNil())
+		Expect(compileFormat("{{.Content")).To(BeNil())
+	})
+
+	It("should be used by the logger for simple formats only", func() {
+		logger := New("format")
+		defer logger.Destroy()
+		Expect(logger.config().compiled).NotTo(BeNil())
+		Expect(logger.SetFormat("{{if .Stack}}{{.Stack}}{{end}}")).To(Succeed())
+		Expect(logger.config().compiled).To(BeNil())
+	})
+})
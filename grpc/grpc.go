@@ -0,0 +1,294 @@
+// Package loggo_grpc provides gRPC interceptors logging calls with loggo
+package loggo_grpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/claudetech/loggo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Options configures the interceptors
+type Options struct {
+	// Returns the level used to log a call finished with the given code.
+	// Defaults to CodeLevel
+	CodeLevel func(codes.Code) loggo.Level
+	// Logs the total size of the request and response messages
+	PayloadSizes bool
+	// Calls to these full method names, e.g. /grpc.health.v1.Health/Check, are not logged
+	SkipMethods []string
+}
+
+// CodeLevel returns the default level for a gRPC code:
+// Info for OK, Warning for errors caused by the client
+// and Error for server errors
+func CodeLevel(code codes.Code) loggo.Level {
+	switch code {
+	case codes.OK:
+		return loggo.Info
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return loggo.Warning
+	default:
+		return loggo.Error
+	}
+}
+
+type interceptor struct {
+	logger *loggo.Logger
+	opts   Options
+	skip   map[string]bool
+}
+
+func newInterceptor(logger *loggo.Logger, opts Options) *interceptor {
+	if opts.CodeLevel == nil {
+		opts.CodeLevel = CodeLevel
+	}
+	skip := make(map[string]bool, len(opts.SkipMethods))
+	for _, method := range opts.SkipMethods {
+		skip[method] = true
+	}
+	return &interceptor{logger: logger, opts: opts, skip: skip}
+}
+
+func splitMethod(fullMethod string) (string, string) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "unknown", name
+}
+
+func payloadSize(msg interface{}) int64 {
+	if m, ok := msg.(proto.Message); ok {
+		return int64(proto.Size(m))
+	}
+	return 0
+}
+
+// callContext returns ctx holding the call fields, and a logger
+// adding them to the messages logged without a context
+func (i *interceptor) callContext(ctx context.Context, fullMethod string, kind string) context.Context {
+	service, method := splitMethod(fullMethod)
+	fields := map[string]interface{}{
+		"grpc.service":   service,
+		"grpc.method":    method,
+		"grpc.call_type": kind,
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields["peer.address"] = p.Addr.String()
+	}
+	ctx = loggo.WithFields(ctx, fields)
+	return loggo.NewContext(ctx, i.logger.WithContext(ctx))
+}
+
+type sizes struct {
+	request  int64
+	response int64
+}
+
+// callSizes returns the payload sizes of a unary call, or nil
+// without computing them unless PayloadSizes is set
+func (i *interceptor) callSizes(req interface{}, resp interface{}, err error) *sizes {
+	if !i.opts.PayloadSizes {
+		return nil
+	}
+	s := &sizes{request: payloadSize(req)}
+	if err == nil {
+		s.response = payloadSize(resp)
+	}
+	return s
+}
+
+// streamSizes returns the sizes to update during a streaming call,
+// or nil unless PayloadSizes is set
+func (i *interceptor) streamSizes() *sizes {
+	if !i.opts.PayloadSizes {
+		return nil
+	}
+	return &sizes{}
+}
+
+func (s *sizes) addRequest(m interface{}) {
+	if s != nil {
+		atomic.AddInt64(&s.request, payloadSize(m))
+	}
+}
+
+func (s *sizes) addResponse(m interface{}) {
+	if s != nil {
+		atomic.AddInt64(&s.response, payloadSize(m))
+	}
+}
+
+func (i *interceptor) logCall(ctx context.Context, side string, fullMethod string, start time.Time, err error, s *sizes) {
+	code := status.Code(err)
+	level := i.opts.CodeLevel(code)
+	if !i.logger.Enabled(level) {
+		return
+	}
+	duration := time.Since(start)
+	fields := map[string]interface{}{
+		"grpc.code":     code.String(),
+		"grpc.duration": duration,
+	}
+	if s != nil {
+		fields["grpc.request.size"] = atomic.LoadInt64(&s.request)
+		fields["grpc.response.size"] = atomic.LoadInt64(&s.response)
+	}
+	content := fmt.Sprintf("finished %s call %s with code %s in %s", side, fullMethod, code, duration)
+	if err != nil {
+		content += ": " + status.Convert(err).Message()
+	}
+	i.logger.LogCtx(loggo.WithFields(ctx, fields), level, content)
+}
+
+// UnaryServerInterceptor returns an interceptor logging unary calls.
+// The handlers receive a context holding the call fields and a logger adding
+// them to every message, which can be retrieved with loggo.FromContext.
+func UnaryServerInterceptor(logger *loggo.Logger, opts Options) grpc.UnaryServerInterceptor {
+	i := newInterceptor(logger, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if i.skip[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		ctx = i.callContext(ctx, info.FullMethod, "unary")
+		resp, err := handler(ctx, req)
+		i.logCall(ctx, "server", info.FullMethod, start, err, i.callSizes(req, resp, err))
+		return resp, err
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	sizes *sizes
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sizes.addResponse(m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.sizes.addRequest(m)
+	}
+	return err
+}
+
+// StreamServerInterceptor returns an interceptor logging streaming calls.
+// The stream context holds the call fields and a logger adding them to every message.
+func StreamServerInterceptor(logger *loggo.Logger, opts Options) grpc.StreamServerInterceptor {
+	i := newInterceptor(logger, opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if i.skip[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		stream := &serverStream{
+			ServerStream: ss,
+			ctx:          i.callContext(ss.Context(), info.FullMethod, "stream"),
+			sizes:        i.streamSizes(),
+		}
+		err := handler(srv, stream)
+		i.logCall(stream.ctx, "server", info.FullMethod, start, err, stream.sizes)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor logging unary calls made by a client
+func UnaryClientInterceptor(logger *loggo.Logger, opts Options) grpc.UnaryClientInterceptor {
+	i := newInterceptor(logger, opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		if i.skip[method] {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		ctx = loggo.WithFields(ctx, map[string]interface{}{"grpc.target": cc.Target()})
+		i.logCall(ctx, "client", method, start, err, i.callSizes(req, reply, err))
+		return err
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	interceptor *interceptor
+	ctx         context.Context
+	method      string
+	start       time.Time
+	sizes       *sizes
+	done        int32
+}
+
+func (s *clientStream) finish(err error) {
+	if atomic.CompareAndSwapInt32(&s.done, 0, 1) {
+		s.interceptor.logCall(s.ctx, "client", s.method, s.start, err, s.sizes)
+	}
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sizes.addRequest(m)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch err {
+	case nil:
+		s.sizes.addResponse(m)
+	case io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+// StreamClientInterceptor returns an interceptor logging streaming calls made by a client.
+// The call is logged when the stream ends, once RecvMsg returns an error or io.EOF.
+func StreamClientInterceptor(logger *loggo.Logger, opts Options) grpc.StreamClientInterceptor {
+	i := newInterceptor(logger, opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if i.skip[method] {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+		start := time.Now()
+		ctx = loggo.WithFields(ctx, map[string]interface{}{"grpc.target": cc.Target()})
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			i.logCall(ctx, "client", method, start, err, nil)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream: cs,
+			interceptor:  i,
+			ctx:          ctx,
+			method:       method,
+			start:        start,
+			sizes:        i.streamSizes(),
+		}, nil
+	}
+}
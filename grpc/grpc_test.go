@@ -0,0 +1,195 @@
+package loggo_grpc
+
+import (
+	"context"
+	"github.com/claudetech/loggo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"sync"
+)
+
+type captureAppender struct {
+	messages []*loggo.Message
+	lock     sync.Mutex
+}
+
+func (c *captureAppender) Append(msg *loggo.Message) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.messages = append(c.messages, msg)
+}
+
+func (c *captureAppender) Messages() []*loggo.Message {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*loggo.Message(nil), c.messages...)
+}
+
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	handlerLogger *loggo.Logger
+}
+
+func (h *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	h.handlerLogger = loggo.FromContext(ctx)
+	h.handlerLogger.Debug("checking")
+	if req.Service == "missing" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	if req.Service == "broken" {
+		return nil, status.Error(codes.Internal, "boom")
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (h *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	for i := 0; i < 2; i++ {
+		err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ = Describe("Interceptors", func() {
+	var serverLogger, clientLogger *loggo.Logger
+	var serverAppender, clientAppender *captureAppender
+	var server *grpc.Server
+	var health *healthServer
+	var conn *grpc.ClientConn
+	var client grpc_health_v1.HealthClient
+
+	BeforeEach(func() {
+		serverLogger = loggo.New("grpc-server")
+		serverAppender = &captureAppender{}
+		serverLogger.AddAppender(serverAppender, loggo.EmptyFlag)
+		clientLogger = loggo.New("grpc-client")
+		clientAppender = &captureAppender{}
+		clientLogger.AddAppender(clientAppender, loggo.EmptyFlag)
+
+		opts := Options{PayloadSizes: true}
+		listener := bufconn.Listen(1 << 20)
+		server = grpc.NewServer(
+			grpc.UnaryInterceptor(UnaryServerInterceptor(serverLogger, opts)),
+			grpc.StreamInterceptor(StreamServerInterceptor(serverLogger, opts)),
+		)
+		health = &healthServer{}
+		grpc_health_v1.RegisterHealthServer(server, health)
+		go server.Serve(listener)
+
+		var err error
+		conn, err = grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientLogger, opts)),
+			grpc.WithStreamInterceptor(StreamClientInterceptor(clientLogger, opts)),
+		)
+		Expect(err).To(BeNil())
+		client = grpc_health_v1.NewHealthClient(conn)
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		server.Stop()
+		serverLogger.Destroy()
+		clientLogger.Destroy()
+	})
+
+	It("should log unary calls", func() {
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "foo"})
+		Expect(err).To(BeNil())
+
+		messages := serverAppender.Messages()
+		Expect(messages).To(HaveLen(2))
+		Expect(messages[0].Content).To(Equal("checking"))
+		Expect(messages[0].Fields).To(HaveKeyWithValue("grpc.method", "Check"))
+		msg := messages[1]
+		Expect(msg.Level).To(Equal(loggo.Info))
+		Expect(msg.Content).To(ContainSubstring("finished server call /grpc.health.v1.Health/Check with code OK"))
+		Expect(msg.Fields).To(HaveKeyWithValue("grpc.service", "grpc.health.v1.Health"))
+		Expect(msg.Fields).To(HaveKeyWithValue("grpc.method", "Check"))
+		Expect(msg.Fields).To(HaveKeyWithValue("grpc.code", "OK"))
+		Expect(msg.Fields).To(HaveKeyWithValue("grpc.request.size", int64(5)))
+		Expect(msg.Fields).To(HaveKeyWithValue("grpc.response.size", int64(2)))
+		Expect(msg.Fields).To(HaveKey("peer.address"))
+
+		messages = clientAppender.Messages()
+		Expect(messages).To(HaveLen(1))
+		Expect(messages[0].Content).To(ContainSubstring("finished client call"))
+		Expect(messages[0].Fields).To(HaveKeyWithValue("grpc.target", "passthrough:///bufnet"))
+	})
+
+	It("should map codes to levels", func() {
+		client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+		client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "broken"})
+		messages := serverAppender.Messages()
+		Expect(messages).To(HaveLen(4))
+		Expect(messages[1].Level).To(Equal(loggo.Warning))
+		Expect(messages[1].Content).To(HaveSuffix(": unknown service"))
+		Expect(messages[3].Level).To(Equal(loggo.Error))
+		Expect(clientAppender.Messages()[1].Fields).To(HaveKeyWithValue("grpc.code", "Internal"))
+	})
+
+	It("should log streaming calls", func() {
+		stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "foo"})
+		Expect(err).To(BeNil())
+		for {
+			if _, err = stream.Recv(); err != nil {
+				break
+			}
+		}
+		Expect(err).To(Equal(io.EOF))
+		Eventually(serverAppender.Messages).Should(HaveLen(1))
+		msg := serverAppender.Messages()[0]
+		Expect(msg.Fields).To(HaveKeyWithValue("grpc.call_type", "stream"))
+		Expect(msg.Fields).To(HaveKeyWithValue("grpc.request.size", int64(5)))
+		Expect(msg.Fields).To(HaveKeyWithValue("grpc.response.size", int64(4)))
+		messages := clientAppender.Messages()
+		Expect(messages).To(HaveLen(1))
+		Expect(messages[0].Fields).To(HaveKeyWithValue("grpc.response.size", int64(4)))
+	})
+
+	It("should skip methods", func() {
+		interceptor := UnaryServerInterceptor(serverLogger, Options{SkipMethods: []string{"/foo/Bar"}})
+		interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/foo/Bar"},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		Expect(serverAppender.Messages()).To(BeEmpty())
+	})
+
+	It("should not log calls below the level of every appender", func() {
+		for _, handle := range serverLogger.Appenders() {
+			handle.SetLevel(loggo.Warning)
+		}
+		interceptor := UnaryServerInterceptor(serverLogger, Options{})
+		info := &grpc.UnaryServerInfo{FullMethod: "/foo/Bar"}
+		interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "missing")
+		})
+		messages := serverAppender.Messages()
+		Expect(messages).To(HaveLen(1))
+		Expect(messages[0].Level).To(Equal(loggo.Warning))
+	})
+
+	It("should not log sizes by default", func() {
+		interceptor := UnaryServerInterceptor(serverLogger, Options{})
+		req := &grpc_health_v1.HealthCheckRequest{Service: "foo"}
+		interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/foo/Bar"},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return req, nil })
+		messages := serverAppender.Messages()
+		Expect(messages).To(HaveLen(1))
+		Expect(messages[0].Fields).NotTo(HaveKey("grpc.request.size"))
+		Expect(messages[0].Fields).NotTo(HaveKey("grpc.response.size"))
+	})
+})
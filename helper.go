@@ -0,0 +1,27 @@
+package loggo
+
+import (
+	"runtime"
+	"sync"
+)
+
+var helpers sync.Map
+
+// Helper marks the calling function as a logging helper function.
+// When looking up the caller information, the function will be skipped,
+// so that File, Line and FuncName refer to the caller of the helper.
+// It works like testing.T.Helper.
+func Helper() {
+	pc, _, _, ok := runtime.Caller(1)
+	if !ok {
+		return
+	}
+	if f := runtime.FuncForPC(pc); f != nil {
+		helpers.Store(f.Name(), struct{}{})
+	}
+}
+
+func isHelper(function string) bool {
+	_, ok := helpers.Load(function)
+	return ok
+}
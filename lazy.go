@@ -0,0 +1,64 @@
+package loggo
+
+import (
+	"fmt"
+)
+
+// Lazy is a log argument which is only evaluated when the message
+// is actually logged, that is when its level is enabled and at least
+// one appender accepts it:
+//
+//	logger.Debug("state: ", loggo.Lazy(func() interface{} { return dumpState() }))
+//
+// With Logf and the like, Lazy arguments should be formatted with %v or %s.
+// Filters run before the lazy arguments are evaluated, so filters
+// inspecting the content must call Message.ResolveContent first.
+type Lazy func() interface{}
+
+// String evaluates f and formats its result.
+// It lets the Lazy values be formatted with %s and %v,
+// even outside of the logger.
+func (f Lazy) String() string {
+	return fmt.Sprint(f())
+}
+
+func hasLazy(v []interface{}) bool {
+	for _, arg := range v {
+		if _, ok := arg.(Lazy); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// deferContent stores a copy of the arguments so that the content is only
+// computed by ResolveContent. Keeping v itself would make the variadic
+// arguments escape, and every disabled log call allocate.
+func (m *Message) deferContent(v []interface{}, sprintf bool) {
+	m.args = append([]interface{}(nil), v...)
+	m.sprintf = sprintf
+}
+
+// ResolveContent evaluates the Lazy arguments of the message, if any,
+// and sets its Content and Causes. It does nothing for messages
+// without Lazy arguments, or which have already been resolved.
+func (m *Message) ResolveContent() {
+	if m.args == nil {
+		return
+	}
+	args := make([]interface{}, len(m.args))
+	for i, arg := range m.args {
+		if lazy, ok := arg.(Lazy); ok && lazy != nil {
+			args[i] = lazy()
+		} else {
+			args[i] = arg
+		}
+	}
+	m.args = nil
+	if m.sprintf {
+		m.Content = fmt.Sprintf(m.ContentFormat, args...)
+	} else {
+		m.Content = fmt.Sprint(args...)
+	}
+	m.Causes = errorCauses(args)
+}
@@ -0,0 +1,94 @@
+package loggo
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"regexp"
+	"testing"
+)
+
+var _ = Describe("Lazy", func() {
+	var logger *Logger
+	var appender *dummyAppender
+	var calls int
+
+	lazy := Lazy(func() interface{} {
+		calls++
+		return "state"
+	})
+
+	BeforeEach(func() {
+		calls = 0
+		logger = New("lazy")
+		logger.SetFormat("{{.Content}}")
+		appender = &dummyAppender{}
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+	})
+
+	It("should evaluate the arguments when logging", func() {
+		logger.AddAppender(appender, 0)
+		logger.Debug("state: ", lazy)
+		logger.Debugf("%s and %d", lazy, 42)
+		Expect(appender.str).To(Equal("state: state\nstate and 42\n"))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("should not evaluate the arguments of disabled levels", func() {
+		logger.AddAppender(appender, 0)
+		logger.SetLevel(Info)
+		logger.Debug(lazy)
+		logger.Debugf("%s", lazy)
+		Expect(calls).To(Equal(0))
+		Expect(appender.str).To(BeEmpty())
+	})
+
+	It("should not allocate for disabled levels", func() {
+		logger.AddAppender(appender, 0)
+		logger.SetLevel(Info)
+		allocs := testing.AllocsPerRun(100, func() {
+			logger.Debug("state: ", lazy)
+			logger.Debugf("%s and %s", lazy, "foo")
+		})
+		Expect(allocs).To(BeZero())
+	})
+
+	It("should not evaluate the arguments rejected by every filter", func() {
+		logger.AddAppenderWithFilter(appender, &MinLogLevelFilter{MinLevel: Error}, 0)
+		logger.Info(lazy)
+		Expect(calls).To(Equal(0))
+		logger.Error(lazy)
+		Expect(calls).To(Equal(1))
+		Expect(appender.str).To(Equal("state\n"))
+	})
+
+	It("should only evaluate the arguments once", func() {
+		logger.AddAppender(appender, 0)
+		logger.AddAppender(appender, Async)
+		logger.Info(lazy)
+		Expect(logger.Flush()).To(Succeed())
+		Expect(calls).To(Equal(1))
+		Expect(appender.str).To(Equal("state\nstate\n"))
+	})
+
+	It("should let content filters see the evaluated content", func() {
+		logger.AddAppenderWithFilter(appender, &ContentRegexpFilter{Regexp: regexp.MustCompile("^state$")}, 0)
+		logger.Info(lazy)
+		Expect(appender.str).To(Equal("state\n"))
+		logger.AddAppenderWithFilter(appender, MustParseFilter(`content ~ "sta"`), 0)
+		logger.Info(lazy)
+		Expect(appender.str).To(Equal("state\nstate\nstate\n"))
+	})
+
+	It("should extract causes from the evaluated arguments", func() {
+		var msg *Message
+		logger.AddAppender(appenderFunc(func(m *Message) { msg = m }), 0)
+		err := errors.New("failed")
+		logger.Error(Lazy(func() interface{} { return err }))
+		Expect(msg.Content).To(Equal("failed"))
+		Expect(msg.Causes).To(Equal([]error{err}))
+	})
+})
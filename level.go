@@ -1,13 +1,21 @@
 package loggo
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Level represents representing the log level
 type Level int32
 
-// Constants representing each log level
+// Constants representing each log level.
+// Custom levels can be registered with RegisterLevel
 const (
 	Trace Level = iota
 	Debug
@@ -17,43 +25,279 @@ const (
 	Fatal
 )
 
+// LevelDefinition describes a log level
+type LevelDefinition struct {
+	// The value of the level
+	Level Level
+	// The severity orders the levels, for SetLevel, filters and appenders.
+	// The built-in levels have a severity equal to their value, so that
+	// custom levels can be ordered between them, e.g. 2.5 for a level between
+	// Info and Warning. Defaults to the value of Level when zero
+	Severity float64
+	// The name of the level, printed upper cased
+	Name string
+	// The color used for the level, see Colors
+	Color string
+	// The syslog severity (RFC 5424) of the level, from 0 (emergency) to 7 (debug)
+	SyslogSeverity int
+}
+
+// levelRegistry holds the registered levels.
+// It is never modified once published, so that it can be read without locking
+type levelRegistry struct {
+	levels    map[Level]LevelDefinition
+	names     map[string]Level
+	nameWidth int
+}
+
+var (
+	registry   atomic.Value
+	levelsLock sync.Mutex
+)
+
+func init() {
+	defs := []LevelDefinition{
+		{Level: Trace, Severity: 0, Name: "TRACE", Color: "white", SyslogSeverity: 7},
+		{Level: Debug, Severity: 1, Name: "DEBUG", Color: "blue", SyslogSeverity: 7},
+		{Level: Info, Severity: 2, Name: "INFO", Color: "cyan", SyslogSeverity: 6},
+		{Level: Warning, Severity: 3, Name: "WARNING", Color: "yellow", SyslogSeverity: 4},
+		{Level: Error, Severity: 4, Name: "ERROR", Color: "magenta", SyslogSeverity: 3},
+		{Level: Fatal, Severity: 5, Name: "FATAL", Color: "red", SyslogSeverity: 2},
+	}
+	r := &levelRegistry{
+		levels: make(map[Level]LevelDefinition, len(defs)),
+		names:  make(map[string]Level, len(defs)),
+	}
+	for _, def := range defs {
+		r.add(def)
+	}
+	registry.Store(r)
+}
+
+func loadLevels() *levelRegistry {
+	return registry.Load().(*levelRegistry)
+}
+
+func (r *levelRegistry) add(def LevelDefinition) {
+	r.levels[def.Level] = def
+	r.names[strings.ToLower(def.Name)] = def.Level
+	if len(def.Name) > r.nameWidth {
+		r.nameWidth = len(def.Name)
+	}
+}
+
+// RegisterLevel registers a custom log level, for example
+//
+//	const Notice loggo.Level = 10
+//	loggo.RegisterLevel(loggo.LevelDefinition{Level: Notice, Severity: 2.5, Name: "NOTICE", Color: "green", SyslogSeverity: 5})
+//
+// Levels should be registered during initialization, before being used by loggers.
+// Registering a level with a value, a name or a severity already in use returns an error
+func RegisterLevel(def LevelDefinition) error {
+	if def.Name == "" {
+		return fmt.Errorf("level name cannot be empty")
+	}
+	if def.SyslogSeverity < 0 || def.SyslogSeverity > 7 {
+		return fmt.Errorf("invalid syslog severity %d", def.SyslogSeverity)
+	}
+	if def.Level == LowestLevel {
+		return fmt.Errorf("level %d is reserved", def.Level)
+	}
+	if def.Severity == 0 {
+		def.Severity = float64(def.Level)
+	}
+	if math.IsNaN(def.Severity) || math.IsInf(def.Severity, 0) || def.Severity <= LowestLevel.Severity() {
+		return fmt.Errorf("invalid level severity %v", def.Severity)
+	}
+	def.Name = strings.ToUpper(def.Name)
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+	current := loadLevels()
+	if existing, ok := current.levels[def.Level]; ok {
+		return fmt.Errorf("level %d is already registered as %s", def.Level, existing.Name)
+	}
+	if _, ok := current.names[strings.ToLower(def.Name)]; ok {
+		return fmt.Errorf("level %s is already registered", def.Name)
+	}
+	for _, existing := range current.levels {
+		if existing.Severity == def.Severity {
+			return fmt.Errorf("severity %v is already used by %s", def.Severity, existing.Name)
+		}
+	}
+	r := &levelRegistry{
+		levels:    make(map[Level]LevelDefinition, len(current.levels)+1),
+		names:     make(map[string]Level, len(current.names)+1),
+		nameWidth: current.nameWidth,
+	}
+	for _, existing := range current.levels {
+		r.add(existing)
+	}
+	r.add(def)
+	registry.Store(r)
+	return nil
+}
+
+// Levels returns the definitions of all the registered levels, by increasing severity
+func Levels() []LevelDefinition {
+	levels := loadLevels().levels
+	defs := make([]LevelDefinition, 0, len(levels))
+	for _, def := range levels {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Severity < defs[j].Severity })
+	return defs
+}
+
+// Severity returns the severity used to order the level.
+// Unregistered levels have a severity equal to their value
+func (l Level) Severity() float64 {
+	if l >= Trace && l <= Fatal {
+		return float64(l)
+	}
+	if def, ok := loadLevels().levels[l]; ok {
+		return def.Severity
+	}
+	return float64(l)
+}
+
+// AtLeast returns true if the severity of the level is greater
+// than or equal to the severity of other
+func (l Level) AtLeast(other Level) bool {
+	if l == other {
+		return true
+	}
+	return l.Severity() >= other.Severity()
+}
+
 // Returns a string representation of the log level
 func (l Level) String() string {
-	switch l {
-	case Trace:
-		return "TRACE"
-	case Debug:
-		return "DEBUG"
-	case Info:
-		return "INFO"
-	case Warning:
-		return "WARNING"
-	case Error:
-		return "ERROR"
-	case Fatal:
-		return "FATAL"
+	if def, ok := loadLevels().levels[l]; ok {
+		return def.Name
+	}
+	return "UNKNOWN"
+}
+
+// SyslogSeverity returns the syslog severity of the level.
+// Unregistered levels use the severity of the closest registered level below them
+func (l Level) SyslogSeverity() int {
+	registry := loadLevels()
+	if def, ok := registry.levels[l]; ok {
+		return def.SyslogSeverity
+	}
+	severity := 7
+	found := false
+	var closest float64
+	for _, def := range registry.levels {
+		if def.Severity <= float64(l) && (!found || def.Severity > closest) {
+			closest, severity, found = def.Severity, def.SyslogSeverity, true
+		}
+	}
+	return severity
+}
+
+// levelColor returns the color set in Colors for the level,
+// or the color it was registered with
+func levelColor(l Level) string {
+	if color, ok := Colors[l]; ok {
+		return color
+	}
+	return loadLevels().levels[l].Color
+}
+
+func levelNameWidth() int {
+	return loadLevels().nameWidth
+}
+
+// ParseLevel returns the level with the given name, ignoring case.
+// It also accepts unambiguous prefixes of the level names, such as "warn" or "err",
+// and numeric values, such as "3"
+func ParseLevel(level string) (Level, error) {
+	name := strings.ToLower(strings.TrimSpace(level))
+	if n, err := strconv.ParseInt(name, 10, 32); err == nil {
+		return Level(n), nil
+	}
+	names := loadLevels().names
+	if l, ok := names[name]; ok {
+		return l, nil
+	}
+	var matches []Level
+	if name != "" {
+		for levelName, l := range names {
+			if strings.HasPrefix(levelName, name) {
+				matches = append(matches, l)
+			}
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return Info, fmt.Errorf("unknown log level %q", level)
 	default:
-		return "UNKNOWN"
+		return Info, fmt.Errorf("ambiguous log level %q", level)
 	}
 }
 
-// Returns the log level from the passed string
+// Returns the log level from the passed string, see ParseLevel
 // Returns Info if the string is not a correct log level
 func LevelFromString(level string) Level {
-	switch strings.ToLower(level) {
-	case "trace":
-		return Trace
-	case "debug":
-		return Debug
-	case "info":
-		return Info
-	case "warning":
-		return Warning
-	case "error":
-		return Error
-	case "fatal":
-		return Fatal
-	default:
-		return Info
+	l, _ := ParseLevel(level)
+	return l
+}
+
+// MarshalText returns the name of the level,
+// or its numeric value if it is not registered
+func (l Level) MarshalText() ([]byte, error) {
+	if def, ok := loadLevels().levels[l]; ok {
+		return []byte(def.Name), nil
+	}
+	return []byte(strconv.Itoa(int(l))), nil
+}
+
+// UnmarshalText parses the level with ParseLevel
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// MarshalJSON returns the name of the level as a JSON string
+func (l Level) MarshalJSON() ([]byte, error) {
+	text, err := l.MarshalText()
+	if err != nil {
+		return nil, err
 	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON parses the level from a JSON string or number
+func (l *Level) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		var n int32
+		if json.Unmarshal(data, &n) != nil {
+			return fmt.Errorf("invalid log level %s", data)
+		}
+		*l = Level(n)
+		return nil
+	}
+	return l.UnmarshalText([]byte(str))
+}
+
+// UnmarshalYAML parses the level from a YAML string or number.
+// It is supported by gopkg.in/yaml.v2 and gopkg.in/yaml.v3
+func (l *Level) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+	return l.UnmarshalText([]byte(str))
+}
+
+// Set parses the level with ParseLevel, so that levels can be used with flag.Var
+func (l *Level) Set(value string) error {
+	return l.UnmarshalText([]byte(value))
 }
@@ -1,8 +1,12 @@
 package loggo
 
 import (
+	"encoding/json"
+	"flag"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
 )
 
 var _ = Describe("Level", func() {
@@ -22,3 +26,182 @@ var _ = Describe("Level", func() {
 		})
 	})
 })
+
+var _ = Describe("Custom levels", func() {
+	const Audit = Fatal + 1
+	const Critical = Fatal + 10
+	const Notice Level = 20
+	var saved *levelRegistry
+
+	BeforeEach(func() {
+		saved = loadLevels()
+	})
+
+	AfterEach(func() {
+		registry.Store(saved)
+	})
+
+	It("should keep the values of the built-in levels", func() {
+		Expect([]Level{Trace, Debug, Info, Warning, Error, Fatal}).To(Equal([]Level{0, 1, 2, 3, 4, 5}))
+	})
+
+	It("should register levels", func() {
+		Expect(RegisterLevel(LevelDefinition{Level: Audit, Name: "audit", Color: "green", SyslogSeverity: 5})).To(BeNil())
+		Expect(Audit.String()).To(Equal("AUDIT"))
+		Expect(LevelFromString("Audit")).To(Equal(Audit))
+		Expect(Audit.SyslogSeverity()).To(Equal(5))
+		Expect(levelColor(Audit)).To(Equal("green"))
+		Expect(Levels()[6].Name).To(Equal("AUDIT"))
+	})
+
+	It("should not modify published registries", func() {
+		Expect(RegisterLevel(LevelDefinition{Level: Audit, Name: "AUDIT", SyslogSeverity: 5})).To(BeNil())
+		Expect(saved.levels).NotTo(HaveKey(Audit))
+		Expect(saved.names).NotTo(HaveKey("audit"))
+	})
+
+	It("should reject duplicates", func() {
+		Expect(RegisterLevel(LevelDefinition{Level: Info, Name: "other"})).NotTo(BeNil())
+		Expect(RegisterLevel(LevelDefinition{Level: Audit, Name: "info"})).NotTo(BeNil())
+		Expect(RegisterLevel(LevelDefinition{Level: Audit, Name: ""})).NotTo(BeNil())
+		Expect(RegisterLevel(LevelDefinition{Level: Audit, Name: "audit", SyslogSeverity: 8})).NotTo(BeNil())
+		Expect(RegisterLevel(LevelDefinition{Level: Audit, Name: "audit", Severity: 2})).NotTo(BeNil())
+		Expect(RegisterLevel(LevelDefinition{Level: LowestLevel, Name: "audit"})).NotTo(BeNil())
+	})
+
+	It("should order levels by severity", func() {
+		Expect(RegisterLevel(LevelDefinition{Level: Notice, Severity: 2.5, Name: "NOTICE", SyslogSeverity: 5})).To(BeNil())
+		Expect(Notice.AtLeast(Info)).To(BeTrue())
+		Expect(Notice.AtLeast(Warning)).To(BeFalse())
+		Expect(Warning.AtLeast(Notice)).To(BeTrue())
+		Expect(Notice.Severity()).To(Equal(2.5))
+		Expect(Audit.Severity()).To(Equal(6.0))
+		Expect(Levels()[3].Name).To(Equal("NOTICE"))
+		Expect(Level(3).SyslogSeverity()).To(Equal(4))
+	})
+
+	It("should use the severity with loggers, appenders and filters", func() {
+		Expect(RegisterLevel(LevelDefinition{Level: Notice, Severity: 2.5, Name: "NOTICE", SyslogSeverity: 5})).To(BeNil())
+		logger := New("levels")
+		defer logger.Destroy()
+		appender := &dummyAppender{}
+		filtered := &dummyAppender{}
+		logger.AddAppender(appender, 0)
+		logger.AddAppenderWithFilter(filtered, MustParseFilter("level <= notice"), 0)
+		logger.SetFormat("{{.LevelStr}}|")
+		logger.SetLevel(Notice)
+		Expect(logger.Enabled(Info)).To(BeFalse())
+		Expect(logger.Enabled(Notice)).To(BeTrue())
+		logger.Info("foo")
+		logger.Log(Notice, "foo")
+		logger.Warning("foo")
+		Expect(appender.str).To(Equal("NOTICE |\nWARNING|\n"))
+		Expect(filtered.str).To(Equal("NOTICE |\n"))
+	})
+
+	It("should work with loggers and filters", func() {
+		Expect(RegisterLevel(LevelDefinition{Level: Audit, Name: "AUDIT", SyslogSeverity: 5})).To(BeNil())
+		logger := New("levels")
+		defer logger.Destroy()
+		appender := &dummyAppender{}
+		logger.AddAppenderWithFilter(appender, MustParseFilter("level >= audit"), 0)
+		logger.SetFormat("{{.LevelStr}}|")
+		logger.SetLevel(Audit)
+		logger.Error("foo")
+		logger.Log(Audit, "foo")
+		Expect(appender.str).To(Equal("AUDIT  |\n"))
+	})
+
+	It("should pad to the longest level name", func() {
+		Expect(RegisterLevel(LevelDefinition{Level: Critical, Name: "CRITICAL", SyslogSeverity: 2})).To(BeNil())
+		msg := &Message{Level: Info, padding: true}
+		Expect(msg.LevelStr()).To(Equal("INFO    "))
+	})
+
+	It("should map unregistered levels to syslog severities", func() {
+		Expect(Trace.SyslogSeverity()).To(Equal(7))
+		Expect((Trace - 1).SyslogSeverity()).To(Equal(7))
+		Expect((Fatal + 100).SyslogSeverity()).To(Equal(2))
+	})
+})
+
+var _ = Describe("ParseLevel", func() {
+	It("should parse names, prefixes and numbers", func() {
+		cases := map[string]Level{
+			"WARNING": Warning,
+			"warn":    Warning,
+			"Err":     Error,
+			"f":       Fatal,
+			" info ":  Info,
+			"1":       Debug,
+			"25":      Level(25),
+		}
+		for in, out := range cases {
+			level, err := ParseLevel(in)
+			Expect(err).To(BeNil(), in)
+			Expect(level).To(Equal(out), in)
+		}
+	})
+
+	It("should return errors", func() {
+		for _, in := range []string{"", "foo", "warnings"} {
+			_, err := ParseLevel(in)
+			Expect(err).NotTo(BeNil(), in)
+		}
+	})
+
+	It("should reject ambiguous prefixes", func() {
+		defer registry.Store(loadLevels())
+		Expect(RegisterLevel(LevelDefinition{Level: Fatal + 1, Name: "EMERGENCY", SyslogSeverity: 0})).To(BeNil())
+		_, err := ParseLevel("e")
+		Expect(err).To(MatchError(ContainSubstring("ambiguous")))
+		Expect(ParseLevel("em")).To(Equal(Fatal + 1))
+	})
+})
+
+var _ = Describe("Level encoding", func() {
+	type config struct {
+		Level Level `json:"level" yaml:"level"`
+	}
+
+	It("should marshal text", func() {
+		Expect(Warning.MarshalText()).To(Equal([]byte("WARNING")))
+		Expect(Level(25).MarshalText()).To(Equal([]byte("25")))
+		var level Level
+		Expect(level.UnmarshalText([]byte("debug"))).To(BeNil())
+		Expect(level).To(Equal(Debug))
+		Expect(level.UnmarshalText([]byte("foo"))).NotTo(BeNil())
+	})
+
+	It("should marshal JSON", func() {
+		data, err := json.Marshal(config{Level: Error})
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal(`{"level":"ERROR"}`))
+		var c config
+		Expect(json.Unmarshal([]byte(`{"level":"warn"}`), &c)).To(BeNil())
+		Expect(c.Level).To(Equal(Warning))
+		Expect(json.Unmarshal([]byte(`{"level":4}`), &c)).To(BeNil())
+		Expect(c.Level).To(Equal(Error))
+		Expect(json.Unmarshal([]byte(`{"level":"foo"}`), &c)).NotTo(BeNil())
+		Expect(json.Unmarshal([]byte(`{"level":true}`), &c)).NotTo(BeNil())
+	})
+
+	It("should unmarshal YAML", func() {
+		var c config
+		Expect(yaml.Unmarshal([]byte("level: fatal"), &c)).To(BeNil())
+		Expect(c.Level).To(Equal(Fatal))
+		Expect(yaml.Unmarshal([]byte("level: 1"), &c)).To(BeNil())
+		Expect(c.Level).To(Equal(Debug))
+		Expect(yaml.Unmarshal([]byte("level: foo"), &c)).NotTo(BeNil())
+	})
+
+	It("should be usable as a flag", func() {
+		level := Info
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		flags.Var(&level, "level", "log level")
+		Expect(flags.Parse([]string{"-level", "trace"})).To(BeNil())
+		Expect(level).To(Equal(Trace))
+		Expect(flags.Parse([]string{"-level", "foo"})).NotTo(BeNil())
+	})
+})
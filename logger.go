@@ -1,6 +1,7 @@
 package loggo
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"runtime"
@@ -18,10 +19,88 @@ const (
 
 // Logger is the basic struct for all logging operations
 type Logger struct {
+	*loggerCore
+	callerSkip int
+	// ctx is used by the log methods called without a context
+	ctx context.Context
+}
+
+// loggerCore holds the state shared by a logger
+// and the loggers derived from it
+type loggerCore struct {
+	// cfg holds the current *loggerConfig. It is replaced as a whole
+	// on every change, so that logging never needs to take a lock
+	cfg   atomic.Value
+	level Level
+	// pending tracks the running async appends
+	pending pendingAppends
+	// wlock serializes the configuration changes
+	wlock sync.Mutex
+	// lastID is the id of the last added appender
+	lastID int
+}
+
+// pendingAppends tracks the running async appends. Unlike a sync.WaitGroup,
+// appends can start while another goroutine waits, which then only waits
+// for the appends started before it
+type pendingAppends struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	// epoch is incremented by every wait, and counts holds
+	// the number of running appends started in each epoch
+	epoch  uint64
+	counts map[uint64]int
+}
+
+// add records a new append, and returns the epoch to pass to done
+func (p *pendingAppends) add() uint64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.counts == nil {
+		p.counts = make(map[uint64]int)
+		p.cond = sync.NewCond(&p.lock)
+	}
+	p.counts[p.epoch]++
+	return p.epoch
+}
+
+// done records the end of an append started in the given epoch
+func (p *pendingAppends) done(epoch uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.counts[epoch]--; p.counts[epoch] == 0 {
+		delete(p.counts, epoch)
+		p.cond.Broadcast()
+	}
+}
+
+// wait waits for the appends started before the call
+func (p *pendingAppends) wait() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	last := p.epoch
+	p.epoch++
+	for p.running(last) {
+		p.cond.Wait()
+	}
+}
+
+// running returns true if appends started in last or before are still running
+func (p *pendingAppends) running(last uint64) bool {
+	for epoch := range p.counts {
+		if epoch <= last {
+			return true
+		}
+	}
+	return false
+}
+
+// loggerConfig is an immutable snapshot of the logger configuration
+type loggerConfig struct {
 	name       string
 	format     string
 	tpl        *template.Template
-	level      Level
+	compiled   *compiledFormat
 	appenders  []*appenderContainer
 	linebreak  string
 	nowFunc    func() time.Time
@@ -29,48 +108,74 @@ type Logger struct {
 	color      bool
 	padding    bool
 	callerInfo bool
-	wlock      sync.Mutex
+	// forceCallerInfo captures caller info regardless of the format
+	forceCallerInfo bool
+	stackTrace      bool
+	stackLevel      Level
+	// minLevel is the lowest level accepted by the appenders
+	minLevel Level
 }
 
 // New creates a new logger and registers it.
 // The logger can then either be used directly
 // or retreived using the name passed as argument.
 func New(name string) *Logger {
-	logger := &Logger{
-		level:      Debug,
+	logger := &Logger{loggerCore: &loggerCore{level: Debug}}
+	logger.cfg.Store(&loggerConfig{
 		nowFunc:    time.Now,
 		name:       name,
 		linebreak:  "\n",
+		minLevel:   LowestLevel,
 		dateFormat: defaultDateFormat,
 		color:      true,
 		padding:    true,
 		callerInfo: false,
-	}
+	})
 	logger.SetFormat(defaultFormat)
+	loggersLock.Lock()
 	loggers[name] = logger
+	loggersLock.Unlock()
 	return logger
 }
 
-// Name returns the name of the logger
-func (l *Logger) Name() string {
+// config returns the current configuration snapshot
+func (l *Logger) config() *loggerConfig {
+	return l.cfg.Load().(*loggerConfig)
+}
+
+// update applies f to a copy of the configuration,
+// which replaces the current one if f succeeds
+func (l *Logger) update(f func(c *loggerConfig) error) error {
 	l.wlock.Lock()
 	defer l.wlock.Unlock()
-	return l.name
+	c := *l.config()
+	if err := f(&c); err != nil {
+		return err
+	}
+	l.cfg.Store(&c)
+	return nil
+}
+
+// Name returns the name of the logger
+func (l *Logger) Name() string {
+	return l.config().name
 }
 
 // SetName set the name of the logger
 func (l *Logger) SetName(name string) {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	l.name = name
+	l.update(func(c *loggerConfig) error {
+		c.name = name
+		return nil
+	})
 }
 
 // SetNowFunc set the function used to get the current time.
 // Defaults to time.Now
 func (l *Logger) SetNowFunc(f func() time.Time) {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	l.nowFunc = f
+	l.update(func(c *loggerConfig) error {
+		c.nowFunc = f
+		return nil
+	})
 }
 
 // Level returns the current log level
@@ -85,36 +190,35 @@ func (l *Logger) SetLevel(level Level) {
 
 // Linebreak returns the string used as linebreak
 func (l *Logger) Linebreak() string {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	return l.linebreak
+	return l.config().linebreak
 }
 
 // SetLineBreak set the string to use as linebreak
 // Defaults to "\n"
 func (l *Logger) SetLineBreak(linebreak string) error {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	strings.TrimRight(l.format, l.linebreak)
-	l.format += linebreak
-	l.linebreak = linebreak
-	return l.updateTemplate(l.format)
+	return l.update(func(c *loggerConfig) error {
+		strings.TrimRight(c.format, c.linebreak)
+		c.format += linebreak
+		c.linebreak = linebreak
+		return c.updateTemplate(c.format)
+	})
 }
 
-func (l *Logger) updateTemplate(format string) error {
-	if !strings.HasSuffix(format, l.linebreak) {
-		format += l.linebreak
+func (c *loggerConfig) updateTemplate(format string) error {
+	if !strings.HasSuffix(format, c.linebreak) {
+		format += c.linebreak
 	}
 	tpl, err := template.New("loggerTemplate").Parse(format)
 	if err != nil {
 		return err
 	}
-	l.format = format
-	l.tpl = tpl
-	l.callerInfo = false
+	c.format = format
+	c.tpl = tpl
+	c.compiled = compileFormat(format)
+	c.callerInfo = false
 	for _, str := range []string{"{{.Line}}", "{{.File}}", "{{.FuncName}}"} {
-		if strings.Contains(l.format, str) {
-			l.callerInfo = true
+		if strings.Contains(c.format, str) {
+			c.callerInfo = true
 			break
 		}
 	}
@@ -123,223 +227,522 @@ func (l *Logger) updateTemplate(format string) error {
 
 // Format returns the current format
 func (l *Logger) Format() string {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	return l.format
+	return l.config().format
 }
 
 // SetFormat set the current format
 // Defaults to "[{{.NameUp}}] [{{.TimeStr}}] {{.LevelStr}}: {{.Content}}"
 func (l *Logger) SetFormat(format string) error {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	return l.updateTemplate(format)
+	return l.update(func(c *loggerConfig) error {
+		return c.updateTemplate(format)
+	})
 }
 
 // DateFormat returns the date format
 func (l *Logger) DateFormat() string {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	return l.dateFormat
+	return l.config().dateFormat
 }
 
 // SetDateFormat set the date format
 // Defaults to "2006-01-02 15:04"
 func (l *Logger) SetDateFormat(format string) {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	l.dateFormat = format
+	l.update(func(c *loggerConfig) error {
+		c.dateFormat = format
+		return nil
+	})
 }
 
-// AddAppender adds an appender to the logger
-func (l *Logger) AddAppender(appender Appender, flags int) {
-	l.AddAppenderWithFilter(appender, nil, flags)
+// AddAppender adds an appender to the logger.
+// The returned handle allows to configure the appender afterwards.
+func (l *Logger) AddAppender(appender Appender, flags int) *AppenderHandle {
+	return l.AddAppenderWithFilter(appender, nil, flags)
 }
 
 // AddAppenderWithFilter adds an appender with a filter to the logger
-func (l *Logger) AddAppenderWithFilter(appender Appender, filter Filter, flags int) {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
+func (l *Logger) AddAppenderWithFilter(appender Appender, filter Filter, flags int) *AppenderHandle {
 	container := &appenderContainer{
 		appender: appender,
 		filter:   filter,
 		flags:    flags,
+		level:    LowestLevel,
+		state:    &appenderState{},
 	}
-	l.appenders = append(l.appenders, container)
+	l.update(func(c *loggerConfig) error {
+		l.lastID++
+		container.id = l.lastID
+		// never append in place, the previous slice may still be in use
+		appenders := make([]*appenderContainer, len(c.appenders), len(c.appenders)+1)
+		copy(appenders, c.appenders)
+		c.appenders = append(appenders, container)
+		c.updateMinLevel()
+		return nil
+	})
+	return &AppenderHandle{logger: l, id: container.id}
 }
 
 // Color returns the current status for global color
 func (l *Logger) Color() bool {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	return l.color
+	return l.config().color
 }
 
 // EnableColor enables color globally.
 // Will allow appenders added with the `color` option
 // to use colors.
 func (l *Logger) EnableColor() {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	l.color = true
+	l.update(func(c *loggerConfig) error {
+		c.color = true
+		return nil
+	})
 }
 
 // DisableColor disables color globally.
 // Event appenders added with the `color` option
 // will not use colors.
 func (l *Logger) DisableColor() {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	l.color = true
+	l.update(func(c *loggerConfig) error {
+		c.color = true
+		return nil
+	})
 }
 
 // EnablePadding enables padding so that all log level
 // strings print with the same length.
 func (l *Logger) EnablePadding() {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	l.padding = true
+	l.update(func(c *loggerConfig) error {
+		c.padding = true
+		return nil
+	})
 }
 
 // DisablePadding disables padding
 func (l *Logger) DisablePadding() {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	l.padding = false
+	l.update(func(c *loggerConfig) error {
+		c.padding = false
+		return nil
+	})
+}
+
+// WithCallerSkip returns a logger sharing the configuration and appenders of l,
+// which skips n additional stack frames when looking up the caller information.
+// It is useful for loggers used by wrapper functions, so that File, Line
+// and FuncName refer to the caller of the wrapper.
+func (l *Logger) WithCallerSkip(n int) *Logger {
+	return &Logger{loggerCore: l.loggerCore, callerSkip: l.callerSkip + n, ctx: l.ctx}
+}
+
+// WithContext returns a logger sharing the configuration and appenders of l,
+// which logs the messages logged without a context, e.g. with Info, as if
+// they were logged with ctx. The fields held by ctx are then added to every message.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	return &Logger{loggerCore: l.loggerCore, callerSkip: l.callerSkip, ctx: ctx}
+}
+
+// CallerSkip returns the number of additional stack frames skipped
+// when looking up the caller information
+func (l *Logger) CallerSkip() int {
+	return l.callerSkip
+}
+
+// EnableCallerInfo always captures the caller information,
+// even when the format does not use it, so that it can be used
+// by filters and appenders.
+func (l *Logger) EnableCallerInfo() {
+	l.update(func(c *loggerConfig) error {
+		c.forceCallerInfo = true
+		return nil
+	})
+}
+
+// DisableCallerInfo only captures the caller information when the format uses it
+func (l *Logger) DisableCallerInfo() {
+	l.update(func(c *loggerConfig) error {
+		c.forceCallerInfo = false
+		return nil
+	})
+}
+
+// EnableStackTrace captures the stack trace of the messages
+// logged with a level greater or equal to the given level.
+// The stack trace is available in templates as {{.Stack}}
+func (l *Logger) EnableStackTrace(level Level) {
+	l.update(func(c *loggerConfig) error {
+		c.stackTrace = true
+		c.stackLevel = level
+		return nil
+	})
+}
+
+// DisableStackTrace stops capturing stack traces
+func (l *Logger) DisableStackTrace() {
+	l.update(func(c *loggerConfig) error {
+		c.stackTrace = false
+		return nil
+	})
+}
+
+// EffectiveLevel returns the lowest level actually logged, which is the
+// highest of the logger level and of the lowest level of its appenders
+func (l *Logger) EffectiveLevel() Level {
+	level := l.Level()
+	if minLevel := l.config().minLevel; !level.AtLeast(minLevel) {
+		return minLevel
+	}
+	return level
+}
+
+// Enabled returns true if messages with the given level are logged.
+// It can be used to avoid computing the arguments of disabled log calls
+func (l *Logger) Enabled(level Level) bool {
+	return level.AtLeast(l.Level()) && level.AtLeast(l.config().minLevel)
 }
 
 // Tracef formats the given interfaces and logs with Trace level
 func (l *Logger) Tracef(format string, v ...interface{}) {
-	l.logf(Trace, format, v...)
+	l.logf(nil, Trace, format, v...)
 }
 
 // Debugf formats the given interfaces and logs with Debug level
 func (l *Logger) Debugf(format string, v ...interface{}) {
-	l.logf(Debug, format, v...)
+	l.logf(nil, Debug, format, v...)
 }
 
 // Infof formats the given interfaces and logs with Info level
 func (l *Logger) Infof(format string, v ...interface{}) {
-	l.logf(Info, format, v...)
+	l.logf(nil, Info, format, v...)
 }
 
 // Warningf formats the given interfaces and logs with Warning level
 func (l *Logger) Warningf(format string, v ...interface{}) {
-	l.logf(Warning, format, v...)
+	l.logf(nil, Warning, format, v...)
 }
 
 // Errorf formats the given interfaces and logs with Error level
 func (l *Logger) Errorf(format string, v ...interface{}) {
-	l.logf(Error, format, v...)
+	l.logf(nil, Error, format, v...)
 }
 
 // Fatalf formats the given interfaces and logs with Fatal level
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	l.logf(Fatal, format, v...)
+	l.logf(nil, Fatal, format, v...)
 }
 
 // Trace fogs the the given interfaces with Trace level
 func (l *Logger) Trace(v ...interface{}) {
-	l.log(Trace, v...)
+	l.log(nil, Trace, v...)
 }
 
 // Debug fogs the the given interfaces with Debug level
 func (l *Logger) Debug(v ...interface{}) {
-	l.log(Debug, v...)
+	l.log(nil, Debug, v...)
 }
 
 // Info fogs the the given interfaces with Info level
 func (l *Logger) Info(v ...interface{}) {
-	l.log(Info, v...)
+	l.log(nil, Info, v...)
 }
 
 // Warning fogs the the given interfaces with Warning level
 func (l *Logger) Warning(v ...interface{}) {
-	l.log(Warning, v...)
+	l.log(nil, Warning, v...)
 }
 
 // Error fogs the the given interfaces with Error level
 func (l *Logger) Error(v ...interface{}) {
-	l.log(Error, v...)
+	l.log(nil, Error, v...)
 }
 
 // Fatal fogs the the given interfaces with Fatal level
 func (l *Logger) Fatal(v ...interface{}) {
-	l.log(Fatal, v...)
-}
-
-func (l *Logger) makeMessage(level Level, str string) *Message {
-	msg := &Message{
-		Name:       l.Name(),
-		Level:      level,
-		Content:    str,
-		Time:       l.nowFunc(),
-		dateFormat: l.DateFormat(),
-		padding:    l.padding,
-		tpl:        l.tpl,
+	l.log(nil, Fatal, v...)
+}
+
+// TracefCtx formats the given interfaces and logs with Trace level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) TracefCtx(ctx context.Context, format string, v ...interface{}) {
+	l.logf(ctx, Trace, format, v...)
+}
+
+// DebugfCtx formats the given interfaces and logs with Debug level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) DebugfCtx(ctx context.Context, format string, v ...interface{}) {
+	l.logf(ctx, Debug, format, v...)
+}
+
+// InfofCtx formats the given interfaces and logs with Info level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) InfofCtx(ctx context.Context, format string, v ...interface{}) {
+	l.logf(ctx, Info, format, v...)
+}
+
+// WarningfCtx formats the given interfaces and logs with Warning level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) WarningfCtx(ctx context.Context, format string, v ...interface{}) {
+	l.logf(ctx, Warning, format, v...)
+}
+
+// ErrorfCtx formats the given interfaces and logs with Error level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) ErrorfCtx(ctx context.Context, format string, v ...interface{}) {
+	l.logf(ctx, Error, format, v...)
+}
+
+// FatalfCtx formats the given interfaces and logs with Fatal level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) FatalfCtx(ctx context.Context, format string, v ...interface{}) {
+	l.logf(ctx, Fatal, format, v...)
+}
+
+// TraceCtx logs the given interfaces with Trace level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) TraceCtx(ctx context.Context, v ...interface{}) {
+	l.log(ctx, Trace, v...)
+}
+
+// DebugCtx logs the given interfaces with Debug level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) DebugCtx(ctx context.Context, v ...interface{}) {
+	l.log(ctx, Debug, v...)
+}
+
+// InfoCtx logs the given interfaces with Info level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) InfoCtx(ctx context.Context, v ...interface{}) {
+	l.log(ctx, Info, v...)
+}
+
+// WarningCtx logs the given interfaces with Warning level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) WarningCtx(ctx context.Context, v ...interface{}) {
+	l.log(ctx, Warning, v...)
+}
+
+// ErrorCtx logs the given interfaces with Error level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) ErrorCtx(ctx context.Context, v ...interface{}) {
+	l.log(ctx, Error, v...)
+}
+
+// FatalCtx logs the given interfaces with Fatal level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) FatalCtx(ctx context.Context, v ...interface{}) {
+	l.log(ctx, Fatal, v...)
+}
+
+// messagePool holds the messages released by outputLog
+var messagePool = sync.Pool{New: func() interface{} { return &Message{} }}
+
+// makeMessage creates a message logged from the caller of the skip functions above it
+func (l *Logger) makeMessage(ctx context.Context, level Level, format string, content interface{}, v []interface{}, skip int) *Message {
+	c := l.config()
+	if ctx == nil {
+		ctx = l.ctx
 	}
-	if l.callerInfo {
-		if pc, file, line, ok := runtime.Caller(3); ok {
-			msg.File = file
-			msg.Line = line
-			if f := runtime.FuncForPC(pc); f != nil {
-				msg.FuncName = f.Name()
-			}
+	msg := messagePool.Get().(*Message)
+	*msg = Message{
+		Context:       ctx,
+		Name:          c.name,
+		Level:         level,
+		Content:       content,
+		ContentFormat: format,
+		Causes:        errorCauses(v),
+		Time:          c.nowFunc(),
+		dateFormat:    c.dateFormat,
+		padding:       c.padding,
+		tpl:           c.tpl,
+		format:        c.compiled,
+	}
+	if ctx != nil {
+		msg.Fields = extractContextFields(ctx)
+	}
+	stackTrace := c.stackTrace && level.AtLeast(c.stackLevel)
+	if c.callerInfo || c.forceCallerInfo || stackTrace {
+		frames := l.callerFrames(stackTrace, skip)
+		if len(frames) > 0 {
+			msg.File = frames[0].File
+			msg.Line = frames[0].Line
+			msg.FuncName = frames[0].Function
+		}
+		if stackTrace {
+			msg.Stack = formatStack(frames)
 		}
 	}
 	return msg
 }
 
+// callerFrames returns the frame of the function which called the logger,
+// followed by the rest of the stack when full is true.
+// It must be called from makeMessage, and skip is the number of logger
+// functions above makeMessage, e.g. 2 for log and the public logging method.
+// Runtime frames, such as the ones of a panic, are skipped as well.
+func (l *Logger) callerFrames(full bool, skip int) []runtime.Frame {
+	var pcs [64]uintptr
+	// skip runtime.Callers, callerFrames and makeMessage
+	n := runtime.Callers(3+skip, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	skip = l.callerSkip
+	var result []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		if len(result) > 0 {
+			result = append(result, frame)
+		} else if !isHelper(frame.Function) && !strings.HasPrefix(frame.Function, "runtime.") {
+			if skip <= 0 {
+				result = append(result, frame)
+				if !full {
+					return result
+				}
+			}
+			skip--
+		}
+		if !more {
+			return result
+		}
+	}
+}
+
 // Logf formats interfaces with the given format and logs them with the given level
 func (l *Logger) Logf(level Level, format string, v ...interface{}) {
-	l.logf(level, format, v...)
+	l.logf(nil, level, format, v...)
 }
 
 // Log logs the interfaces with the given level
 func (l *Logger) Log(level Level, v ...interface{}) {
-	l.log(level, v...)
+	l.log(nil, level, v...)
 }
 
-func (l *Logger) logf(level Level, format string, v ...interface{}) {
-	if level < l.Level() {
+// LogfCtx formats interfaces with the given format and logs them with the given level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) LogfCtx(ctx context.Context, level Level, format string, v ...interface{}) {
+	l.logf(ctx, level, format, v...)
+}
+
+// LogCtx logs the interfaces with the given level,
+// attaching the values extracted from ctx to the message
+func (l *Logger) LogCtx(ctx context.Context, level Level, v ...interface{}) {
+	l.log(ctx, level, v...)
+}
+
+func (l *Logger) logf(ctx context.Context, level Level, format string, v ...interface{}) {
+	if !l.Enabled(level) {
 		return
 	}
-	msg := l.makeMessage(level, fmt.Sprintf(format, v...))
+	var msg *Message
+	if hasLazy(v) {
+		msg = l.makeMessage(ctx, level, format, "", nil, 2)
+		msg.deferContent(v, true)
+	} else {
+		msg = l.makeMessage(ctx, level, format, fmt.Sprintf(format, v...), v, 2)
+	}
 	l.outputLog(msg)
 }
 
-func (l *Logger) log(level Level, v ...interface{}) {
-	if level < l.Level() {
+func (l *Logger) log(ctx context.Context, level Level, v ...interface{}) {
+	if !l.Enabled(level) {
 		return
 	}
-	msg := l.makeMessage(level, fmt.Sprint(v...))
+	var msg *Message
+	if hasLazy(v) {
+		msg = l.makeMessage(ctx, level, "", "", nil, 2)
+		msg.deferContent(v, false)
+	} else {
+		msg = l.makeMessage(ctx, level, "", sprint(v), v, 2)
+	}
 	l.outputLog(msg)
 }
 
-func (l *Logger) outputLog(msg *Message) {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
+// sprint formats v like fmt.Sprint, returning single strings as they are
+// so that they are not copied to the heap
+func sprint(v []interface{}) interface{} {
+	if len(v) == 1 {
+		if _, ok := v[0].(string); ok {
+			return v[0]
+		}
+	}
+	return fmt.Sprint(v...)
+}
 
-	for _, container := range l.appenders {
+// outputLog sends the message to the appenders of the current configuration.
+// The lazy arguments are evaluated once the first appender accepts the message.
+// It does not take the logger lock, so a slow appender only blocks
+// the goroutines writing to this same appender.
+// The message is reused afterwards unless an appender may keep it.
+func (l *Logger) outputLog(msg *Message) {
+	c := l.config()
+	release := true
+	for _, container := range c.appenders {
+		if !msg.Level.AtLeast(container.level) {
+			continue
+		}
+		// the filter or the appender may keep the message
+		if _, ok := container.appender.(TransientAppender); !ok {
+			release = false
+		}
 		if container.filter == nil || container.filter.ShouldLog(msg) {
-			msg.color = l.color && (container.flags&Color != 0)
+			msg.ResolveContent()
+			color := c.color && (container.flags&Color != 0)
 			if container.flags&Async == 0 {
+				msg.color = color
 				l.makeAppend(container, msg)
 			} else {
-				go l.makeAppend(container, msg)
+				// the async appender gets its own copy, as msg
+				// keeps being updated for the next appenders
+				async := *msg
+				async.color = color
+				epoch := l.pending.add()
+				go func(container *appenderContainer, msg *Message) {
+					defer l.pending.done(epoch)
+					l.makeAppend(container, msg)
+				}(container, &async)
 			}
 		}
 	}
+	if release {
+		*msg = Message{}
+		messagePool.Put(msg)
+	}
 }
 
 func (l *Logger) makeAppend(container *appenderContainer, msg *Message) {
-	container.wlock.Lock()
-	defer container.wlock.Unlock()
-	container.appender.Append(msg)
+	container.state.lock.Lock()
+	defer container.state.lock.Unlock()
+	if !container.state.closed {
+		container.appender.Append(msg)
+	}
+}
+
+// Flush waits for the messages already sent to Async appenders to be appended,
+// then flushes every appender implementing the Flusher interface.
+// Messages logged concurrently may or may not be flushed
+func (l *Logger) Flush() (err error) {
+	l.pending.wait()
+	for _, container := range l.config().appenders {
+		if e := l.flushAppender(container); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
 }
 
-func (l *Logger) destroyAppender(appender Appender) error {
-	if closer, ok := appender.(io.Closer); ok {
+func (l *Logger) flushAppender(container *appenderContainer) error {
+	container.state.lock.Lock()
+	defer container.state.lock.Unlock()
+	if flusher, ok := container.appender.(Flusher); ok && !container.state.closed {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// destroyAppender marks the container as closed, so that the goroutines
+// still holding a previous configuration do not append to it anymore,
+// then closes the appender
+func (l *Logger) destroyAppender(container *appenderContainer) error {
+	container.state.lock.Lock()
+	defer container.state.lock.Unlock()
+	if container.state.closed {
+		return nil
+	}
+	container.state.closed = true
+	if closer, ok := container.appender.(io.Closer); ok {
 		return closer.Close()
 	}
 	return nil
@@ -348,14 +751,27 @@ func (l *Logger) destroyAppender(appender Appender) error {
 // Destroy destroy the loggers, closing every appender implementing
 // the io.Closer interface
 func (l *Logger) Destroy() (err error) {
-	l.wlock.Lock()
-	defer l.wlock.Unlock()
-	for _, container := range l.appenders {
-		if e := l.destroyAppender(container.appender); e != nil {
+	var containers []*appenderContainer
+	var name string
+	l.update(func(c *loggerConfig) error {
+		containers = c.appenders
+		name = c.name
+		c.appenders = nil
+		c.updateMinLevel()
+		return nil
+	})
+	// let the running async appends complete before closing
+	l.pending.wait()
+	for _, container := range containers {
+		if e := l.destroyAppender(container); e != nil {
 			err = e
 		}
 	}
-	l.appenders = nil
-	delete(loggers, l.name)
+	loggersLock.Lock()
+	// a logger registered later with the same name is kept
+	if registered, ok := loggers[name]; ok && registered.loggerCore == l.loggerCore {
+		delete(loggers, name)
+	}
+	loggersLock.Unlock()
 	return
 }
@@ -2,10 +2,15 @@ package loggo
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"io/ioutil"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 )
@@ -18,6 +23,15 @@ func (d *dummyAppender) Append(msg *Message) {
 	d.str += msg.String()
 }
 
+// keepingAppender keeps the appended messages
+type keepingAppender struct {
+	messages []*Message
+}
+
+func (k *keepingAppender) Append(msg *Message) {
+	k.messages = append(k.messages, msg)
+}
+
 func dummyTime() time.Time {
 	return time.Date(2009, time.November, 10, 15, 0, 0, 0, time.Local)
 }
@@ -102,15 +116,14 @@ var _ = Describe("Logger", func() {
 		logger.SetFormat("{{.Content}}")
 		logger.AddAppender(appender, Async)
 		logger.Debug("foo")
-		Expect(appender.str).To(Equal("foo\n"))
-		time.Sleep(10 * time.Millisecond) // not very safe way to check
+		Expect(logger.Flush()).To(Succeed())
 		Expect(appender.str).To(Equal("foo\nfoo\n"))
 	})
 
 	It("should set logger info", func() {
-		Expect(logger.callerInfo).To(BeFalse())
+		Expect(logger.config().callerInfo).To(BeFalse())
 		logger.SetFormat("{{.Line}}")
-		Expect(logger.callerInfo).To(BeTrue())
+		Expect(logger.config().callerInfo).To(BeTrue())
 	})
 
 	It("should output line number", func() {
@@ -121,8 +134,275 @@ var _ = Describe("Logger", func() {
 		Expect(n).To(BeNumerically(">", 0))
 	})
 
+	It("should skip caller frames", func() {
+		logger.SetFormat("{{.FuncName}}")
+		wrapper := func(l *Logger) {
+			l.Info("foo")
+		}
+		wrapper(logger)
+		Expect(appender.str).To(ContainSubstring(".func"))
+		appender.str = ""
+		logHelperWrapper(logger.WithCallerSkip(1))
+		Expect(appender.str).To(ContainSubstring("func"))
+		Expect(appender.str).NotTo(ContainSubstring("logHelperWrapper"))
+		Expect(logger.WithCallerSkip(1).WithCallerSkip(2).CallerSkip()).To(Equal(3))
+	})
+
+	It("should skip helper functions", func() {
+		logger.SetFormat("{{.FuncName}}")
+		markedHelper(logger)
+		Expect(appender.str).NotTo(ContainSubstring("markedHelper"))
+		Expect(appender.str).To(ContainSubstring("loggo."))
+	})
+
+	It("should share the configuration with derived loggers", func() {
+		derived := logger.WithCallerSkip(1)
+		logger.SetFormat("{{.Content}}")
+		derived.Info("foo")
+		Expect(appender.str).To(Equal("foo\n"))
+	})
+
+	It("should not reuse the messages kept by appenders", func() {
+		keeping := &keepingAppender{}
+		logger.AddAppender(NewWriterAppender(ioutil.Discard), EmptyFlag)
+		handle := logger.AddAppender(keeping, EmptyFlag)
+		logger.Info("foo")
+		logger.Warning("bar")
+		handle.Remove()
+		for i := 0; i < 10; i++ {
+			logger.Error("baz")
+		}
+		Expect(keeping.messages).To(HaveLen(2))
+		Expect(keeping.messages[0].Content).To(Equal("foo"))
+		Expect(keeping.messages[0].Level).To(Equal(Info))
+		Expect(keeping.messages[1].Content).To(Equal("bar"))
+		Expect(keeping.messages[1].Name).To(Equal(logger.Name()))
+	})
+
+	It("should log with the context of derived loggers", func() {
+		ctx := WithFields(context.Background(), map[string]interface{}{"tenant": "acme"})
+		derived := logger.WithContext(ctx).WithCallerSkip(1)
+		logger.SetFormat(`{{.Field "tenant"}} {{.Content}}`)
+		derived.Info("foo")
+		derived.InfoCtx(context.Background(), "bar")
+		logger.Info("baz")
+		Expect(appender.str).To(Equal("acme foo\n<no value> bar\n<no value> baz\n"))
+	})
+
+	It("should force caller info", func() {
+		var msg *Message
+		logger.AddAppenderWithFilter(appender, FilterFunc(func(m *Message) bool {
+			msg = m
+			return false
+		}), 0)
+		logger.Info("foo")
+		Expect(msg.Line).To(Equal(0))
+		logger.EnableCallerInfo()
+		logger.Info("foo")
+		Expect(msg.File).To(HaveSuffix("logger_test.go"))
+		Expect(msg.Line).To(BeNumerically(">", 0))
+		logger.DisableCallerInfo()
+		logger.Info("foo")
+		Expect(msg.Line).To(Equal(0))
+	})
+
+	It("should capture stack traces", func() {
+		logger.SetFormat("{{.Stack}}")
+		logger.EnableStackTrace(Error)
+		logger.Warning("foo")
+		Expect(appender.str).To(Equal("\n"))
+		logger.Error("foo")
+		Expect(appender.str).To(ContainSubstring("logger_test.go"))
+		Expect(strings.Count(appender.str, "\n")).To(BeNumerically(">", 4))
+		Expect(appender.str).NotTo(ContainSubstring("makeMessage"))
+		appender.str = ""
+		logger.DisableStackTrace()
+		logger.Error("foo")
+		Expect(appender.str).To(Equal("\n"))
+	})
+
+	It("should unwrap errors", func() {
+		var msg *Message
+		logger.AddAppenderWithFilter(appender, FilterFunc(func(m *Message) bool {
+			msg = m
+			return false
+		}), 0)
+		root := errors.New("root")
+		wrapped := fmt.Errorf("wrapped: %w", root)
+		logger.Error("failed", wrapped)
+		Expect(msg.Causes).To(Equal([]error{wrapped, root}))
+		other := errors.New("other")
+		logger.Errorf("failed: %v", errors.Join(root, other))
+		Expect(msg.Causes).To(HaveLen(3))
+		Expect(msg.Causes[1:]).To(Equal([]error{root, other}))
+		logger.Error("foo")
+		Expect(msg.Causes).To(BeNil())
+	})
+
 	It("should be destroyed", func() {
 		logger.Destroy()
-		Expect(logger.appenders).To(BeEmpty())
+		Expect(logger.config().appenders).To(BeEmpty())
+	})
+
+	It("should not append to destroyed appenders from a previous configuration", func() {
+		closing := &closingAppender{}
+		logger.AddAppender(closing, 0)
+		stale := logger.config()
+		logger.Destroy()
+		Expect(closing.closed).To(BeTrue())
+		logger.makeAppend(stale.appenders[len(stale.appenders)-1], &Message{Content: "foo"})
+		Expect(closing.str).To(BeEmpty())
 	})
 })
+
+type countingAppender struct {
+	count int
+	delay time.Duration
+}
+
+func (c *countingAppender) Append(msg *Message) {
+	_ = msg.String()
+	time.Sleep(c.delay)
+	c.count++
+}
+
+var _ = Describe("Logger concurrency", func() {
+	var logger *Logger
+
+	BeforeEach(func() {
+		logger = New("concurrent")
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+	})
+
+	It("should flush while other goroutines keep logging to async appenders", func() {
+		appender := &countingAppender{}
+		logger.AddAppender(appender, Async)
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 500; j++ {
+					logger.Info("foo")
+				}
+			}()
+		}
+		logged := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(logged)
+		}()
+		flushed := make(chan struct{})
+		go func() {
+			defer close(flushed)
+			for {
+				select {
+				case <-logged:
+					logger.Flush()
+					return
+				default:
+					logger.Flush()
+				}
+			}
+		}()
+		Eventually(flushed, 10*time.Second).Should(BeClosed())
+		Expect(appender.count).To(Equal(16 * 500))
+	})
+
+	It("should not block configuration changes during slow appends", func() {
+		release := make(chan struct{})
+		started := make(chan struct{})
+		logger.AddAppender(appenderFunc(func(msg *Message) {
+			close(started)
+			<-release
+		}), 0)
+		go logger.Info("slow")
+		<-started
+		done := make(chan struct{})
+		go func() {
+			logger.SetFormat("{{.Content}}")
+			logger.SetName("other")
+			_ = logger.Name()
+			logger.AddAppender(&countingAppender{}, 0)
+			close(done)
+		}()
+		Eventually(done).Should(BeClosed())
+		close(release)
+	})
+
+	It("should not serialize appenders with each other", func() {
+		release := make(chan struct{})
+		started := make(chan struct{}, 1)
+		fast := &countingAppender{}
+		logger.AddAppenderWithFilter(appenderFunc(func(msg *Message) {
+			started <- struct{}{}
+			<-release
+		}), FilterFunc(func(msg *Message) bool { return msg.Content == "slow" }), 0)
+		logger.AddAppenderWithFilter(fast, FilterFunc(func(msg *Message) bool { return msg.Content == "fast" }), 0)
+		go logger.Info("slow")
+		<-started
+		logger.Info("fast")
+		Expect(fast.count).To(Equal(1))
+		close(release)
+	})
+
+	It("should support concurrent logging and configuration changes", func() {
+		appenders := []*countingAppender{{delay: time.Microsecond}, {}, {}}
+		logger.AddAppender(appenders[0], 0)
+		logger.AddAppender(appenders[1], Async)
+		const goroutines, messages = 8, 200
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < messages; j++ {
+					logger.Infof("message %d from %d", j, i)
+				}
+			}(i)
+		}
+		stop := make(chan struct{})
+		configured := make(chan struct{})
+		go func() {
+			defer close(configured)
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				logger.SetFormat(fmt.Sprintf("%d {{.Content}}", i))
+				logger.SetDateFormat(time.RFC3339)
+				logger.EnableCallerInfo()
+				logger.DisableCallerInfo()
+				_ = logger.Format()
+			}
+		}()
+		logger.AddAppender(appenders[2], 0)
+		wg.Wait()
+		close(stop)
+		<-configured
+		Expect(logger.Flush()).To(Succeed())
+		Expect(appenders[0].count).To(Equal(goroutines * messages))
+		Expect(appenders[1].count).To(Equal(goroutines * messages))
+		Expect(appenders[2].count).To(BeNumerically("<=", goroutines*messages))
+	})
+})
+
+type appenderFunc func(msg *Message)
+
+func (f appenderFunc) Append(msg *Message) {
+	f(msg)
+}
+
+func logHelperWrapper(l *Logger) {
+	l.Info("foo")
+}
+
+func markedHelper(l *Logger) {
+	Helper()
+	l.Info("foo")
+}
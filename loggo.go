@@ -1,12 +1,15 @@
 // Package loggo is an easy to use, configurable and extensible logging library
 package loggo
 
+import "sync"
+
 // Flag representing all options turned off
 const EmptyFlag = 0
 
 // Mapping between log level and color
 // names used to display ANSI colors in terminal
-// See https://github.com/mgutz/ansi for more info about accepted values
+// See https://github.com/mgutz/ansi for more info about accepted values.
+// Levels missing from Colors use the color they were registered with
 var Colors = map[Level]string{
 	Trace:   "white",
 	Debug:   "blue",
@@ -16,11 +19,16 @@ var Colors = map[Level]string{
 	Fatal:   "red",
 }
 
-var loggers = make(map[string]*Logger)
+var (
+	loggers     = make(map[string]*Logger)
+	loggersLock sync.RWMutex
+)
 
 // Get retreives the logger with the given name.
 // Returns nil if no such logger exists
 func Get(name string) *Logger {
+	loggersLock.RLock()
+	defer loggersLock.RUnlock()
 	if logger, ok := loggers[name]; ok {
 		return logger
 	}
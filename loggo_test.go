@@ -20,6 +20,28 @@ var _ = Describe("loggo", func() {
 			Expect(Get("foo")).To(BeNil())
 		})
 	})
+
+	Describe("allocations", func() {
+		var logger *Logger
+
+		BeforeEach(func() {
+			logger = New("allocations")
+			logger.AddAppender(NewWriterAppender(ioutil.Discard), EmptyFlag)
+		})
+
+		AfterEach(func() {
+			logger.Destroy()
+		})
+
+		It("should not allocate for disabled levels", func() {
+			logger.SetLevel(Info)
+			allocs := testing.AllocsPerRun(100, func() {
+				logger.Debug("foo")
+				logger.Debugf("foo %s", "bar")
+			})
+			Expect(allocs).To(BeZero())
+		})
+	})
 })
 
 func BenchmarkStdoutLogger(b *testing.B) {
@@ -27,6 +49,7 @@ func BenchmarkStdoutLogger(b *testing.B) {
 	b.StopTimer()
 	logger := New("foo")
 	logger.AddAppender(NewStdoutAppender(), EmptyFlag)
+	b.ReportAllocs()
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
 		logger.Debug("foo")
@@ -39,6 +62,7 @@ func BenchmarkStdoutNotLogged(b *testing.B) {
 	logger := New("foo")
 	logger.SetLevel(Info)
 	logger.AddAppender(NewStdoutAppender(), EmptyFlag)
+	b.ReportAllocs()
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
 		logger.Debug("foo")
@@ -55,6 +79,7 @@ func BenchmarkFileLogger(b *testing.B) {
 		b.Fail()
 	}
 	logger.AddAppender(appender, EmptyFlag)
+	b.ReportAllocs()
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
 		logger.Debug("foo")
@@ -62,3 +87,45 @@ func BenchmarkFileLogger(b *testing.B) {
 	b.StopTimer()
 	os.Remove(filepath)
 }
+
+func BenchmarkStdoutTemplateLogger(b *testing.B) {
+	stdout = ioutil.Discard
+	b.StopTimer()
+	logger := New("foo")
+	logger.SetFormat("{{if .Content}}{{.Content}}{{end}}")
+	logger.AddAppender(NewStdoutAppender(), EmptyFlag)
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debug("foo")
+	}
+	b.StopTimer()
+}
+
+func BenchmarkStdoutNotLoggedWithArgs(b *testing.B) {
+	b.StopTimer()
+	logger := New("foo")
+	logger.SetLevel(Info)
+	logger.AddAppender(NewStdoutAppender(), EmptyFlag)
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("foo %d %s", i, "bar")
+	}
+	b.StopTimer()
+}
+
+func BenchmarkStdoutNotLoggedEnabled(b *testing.B) {
+	b.StopTimer()
+	logger := New("foo")
+	logger.SetLevel(Info)
+	logger.AddAppender(NewStdoutAppender(), EmptyFlag)
+	b.ReportAllocs()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if logger.Enabled(Debug) {
+			logger.Debugf("foo %d %s", i, "bar")
+		}
+	}
+	b.StopTimer()
+}
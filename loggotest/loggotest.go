@@ -0,0 +1,168 @@
+// Package loggotest provides utilities to test code using loggo
+package loggotest
+
+import (
+	"fmt"
+	"github.com/claudetech/loggo"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// CapturingAppender records all the messages it receives
+type CapturingAppender struct {
+	messages []*loggo.Message
+	lines    []string
+	lock     sync.Mutex
+}
+
+// NewCapturingAppender creates a new capturing appender
+func NewCapturingAppender() *CapturingAppender {
+	return &CapturingAppender{}
+}
+
+// Append records the message
+func (c *CapturingAppender) Append(msg *loggo.Message) {
+	line := msg.String()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.messages = append(c.messages, msg)
+	c.lines = append(c.lines, line)
+}
+
+// Messages returns the recorded messages
+func (c *CapturingAppender) Messages() []*loggo.Message {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*loggo.Message(nil), c.messages...)
+}
+
+// Lines returns the recorded messages formatted by the logger
+func (c *CapturingAppender) Lines() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+// String returns all the formatted messages
+func (c *CapturingAppender) String() string {
+	return strings.Join(c.Lines(), "")
+}
+
+// Reset removes all the recorded messages
+func (c *CapturingAppender) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.messages = nil
+	c.lines = nil
+}
+
+// Find returns the recorded messages with the given level
+// whose content contains substring
+func (c *CapturingAppender) Find(level loggo.Level, substring string) []*loggo.Message {
+	var found []*loggo.Message
+	for _, msg := range c.Messages() {
+		if msg.Level == level && strings.Contains(fmt.Sprint(msg.Content), substring) {
+			found = append(found, msg)
+		}
+	}
+	return found
+}
+
+// AssertLogged fails the test if no message with the given level
+// containing substring has been recorded
+func (c *CapturingAppender) AssertLogged(t testing.TB, level loggo.Level, substring string) bool {
+	t.Helper()
+	if len(c.Find(level, substring)) == 0 {
+		t.Errorf("expected a %s message containing %q, got:\n%s", level, substring, c.describe())
+		return false
+	}
+	return true
+}
+
+// AssertNotLogged fails the test if a message with the given level
+// containing substring has been recorded
+func (c *CapturingAppender) AssertNotLogged(t testing.TB, level loggo.Level, substring string) bool {
+	t.Helper()
+	if found := c.Find(level, substring); len(found) > 0 {
+		t.Errorf("expected no %s message containing %q, got %q", level, substring, found[0].Content)
+		return false
+	}
+	return true
+}
+
+func (c *CapturingAppender) describe() string {
+	messages := c.Messages()
+	if len(messages) == 0 {
+		return "  no messages"
+	}
+	lines := make([]string, len(messages))
+	for i, msg := range messages {
+		lines[i] = fmt.Sprintf("  %s: %s", msg.Level, msg.Content)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TestingAppender writes messages with testing.TB.Log,
+// so that they are only shown for failing or verbose tests
+type TestingAppender struct {
+	t testing.TB
+}
+
+// NewTestingAppender returns an appender logging to t
+func NewTestingAppender(t testing.TB) *TestingAppender {
+	return &TestingAppender{t: t}
+}
+
+// Append logs the formatted message to the test
+func (a *TestingAppender) Append(msg *loggo.Message) {
+	a.t.Helper()
+	a.t.Log(strings.TrimRight(msg.String(), "\n"))
+}
+
+// Transient marks the appender as not keeping the messages
+func (a *TestingAppender) Transient() {}
+
+// Clock is a deterministic clock to use with Logger.SetNowFunc
+type Clock struct {
+	now int64
+}
+
+// NewClock creates a clock starting at the given time
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start.UnixNano()}
+}
+
+// Now returns the current time of the clock
+func (c *Clock) Now() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.now))
+}
+
+// Advance moves the clock forward
+func (c *Clock) Advance(d time.Duration) {
+	atomic.AddInt64(&c.now, int64(d))
+}
+
+// Set sets the time of the clock
+func (c *Clock) Set(t time.Time) {
+	atomic.StoreInt64(&c.now, t.UnixNano())
+}
+
+var loggerCount int64
+
+// NewLogger creates a logger with a unique name recording all messages,
+// also writing them to the test log. The logger is destroyed when the test ends.
+func NewLogger(t testing.TB) (*loggo.Logger, *CapturingAppender) {
+	name := fmt.Sprintf("%s-%d", t.Name(), atomic.AddInt64(&loggerCount, 1))
+	logger := loggo.New(name)
+	logger.SetLevel(loggo.Trace)
+	capture := NewCapturingAppender()
+	logger.AddAppender(capture, loggo.EmptyFlag)
+	logger.AddAppender(NewTestingAppender(t), loggo.EmptyFlag)
+	t.Cleanup(func() {
+		_ = logger.Destroy()
+	})
+	return logger, capture
+}
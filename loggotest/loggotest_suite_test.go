@@ -0,0 +1,13 @@
+package loggotest
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLoggotest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Loggotest Suite")
+}
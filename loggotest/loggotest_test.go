@@ -0,0 +1,103 @@
+package loggotest
+
+import (
+	"fmt"
+	"github.com/claudetech/loggo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+	"time"
+)
+
+type fakeT struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Name() string {
+	return "fake"
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Log(args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+
+func (f *fakeT) Cleanup(func()) {}
+
+var _ = Describe("loggotest", func() {
+	var t *fakeT
+	var logger *loggo.Logger
+	var capture *CapturingAppender
+
+	BeforeEach(func() {
+		t = &fakeT{}
+		logger, capture = NewLogger(t)
+		logger.SetFormat("{{.Content}}")
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+	})
+
+	It("should capture messages", func() {
+		logger.Info("foo")
+		logger.Trace("bar")
+		Expect(capture.Lines()).To(Equal([]string{"foo\n", "bar\n"}))
+		Expect(capture.Messages()[1].Level).To(Equal(loggo.Trace))
+		capture.Reset()
+		Expect(capture.String()).To(BeEmpty())
+	})
+
+	It("should write to the test log", func() {
+		logger.Info("foo")
+		Expect(t.logs).To(Equal([]string{"foo"}))
+	})
+
+	It("should assert logged messages", func() {
+		logger.Error("connection refused")
+		Expect(capture.AssertLogged(t, loggo.Error, "refused")).To(BeTrue())
+		Expect(capture.AssertNotLogged(t, loggo.Info, "refused")).To(BeTrue())
+		Expect(t.errors).To(BeEmpty())
+		Expect(capture.AssertLogged(t, loggo.Info, "refused")).To(BeFalse())
+		Expect(capture.AssertNotLogged(t, loggo.Error, "refused")).To(BeFalse())
+		Expect(t.errors).To(HaveLen(2))
+	})
+
+	It("should provide matchers", func() {
+		logger.Warning("disk full")
+		Expect(capture).To(HaveLogged(loggo.Warning, "disk"))
+		Expect(capture).NotTo(HaveLogged(loggo.Error, "disk"))
+		_, err := HaveLogged(loggo.Warning, "disk").Match("foo")
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should provide a deterministic clock", func() {
+		start := time.Date(2015, time.January, 2, 15, 4, 5, 0, time.UTC)
+		clock := NewClock(start)
+		logger.SetNowFunc(clock.Now)
+		logger.Info("foo")
+		clock.Advance(time.Minute)
+		logger.Info("bar")
+		messages := capture.Messages()
+		Expect(messages[0].Time).To(BeTemporally("==", start))
+		Expect(messages[1].Time).To(BeTemporally("==", start.Add(time.Minute)))
+	})
+})
+
+func TestNewLoggerParallel(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			logger, capture := NewLogger(t)
+			logger.Info("foo")
+			capture.AssertLogged(t, loggo.Info, "foo")
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package loggotest
+
+import (
+	"fmt"
+	"github.com/claudetech/loggo"
+	"github.com/onsi/gomega/types"
+)
+
+type loggedMatcher struct {
+	level     loggo.Level
+	substring string
+}
+
+// HaveLogged succeeds if the actual CapturingAppender has recorded
+// a message with the given level containing substring
+func HaveLogged(level loggo.Level, substring string) types.GomegaMatcher {
+	return &loggedMatcher{level: level, substring: substring}
+}
+
+func (m *loggedMatcher) Match(actual interface{}) (bool, error) {
+	capture, ok := actual.(*CapturingAppender)
+	if !ok {
+		return false, fmt.Errorf("HaveLogged expects a *loggotest.CapturingAppender, got %T", actual)
+	}
+	return len(capture.Find(m.level, m.substring)) > 0, nil
+}
+
+func (m *loggedMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected a %s message containing %q, got:\n%s",
+		m.level, m.substring, actual.(*CapturingAppender).describe())
+}
+
+func (m *loggedMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected no %s message containing %q, got:\n%s",
+		m.level, m.substring, actual.(*CapturingAppender).describe())
+}
@@ -0,0 +1,187 @@
+package loggo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	msg  *Message
+	text string
+}
+
+// MemoryAppender keeps the most recent messages in memory
+// and allows to query them, for example to expose them on a debug endpoint.
+type MemoryAppender struct {
+	maxMessages int
+	maxBytes    int
+	bytes       int
+	entries     []memoryEntry
+	lock        sync.RWMutex
+}
+
+// MemoryQuery selects messages from a MemoryAppender.
+// Zero values match all the messages
+type MemoryQuery struct {
+	// Minimum level of the messages
+	MinLevel Level
+	// Logger name, using path.Match syntax
+	Name string
+	// Only messages logged at or after Since
+	Since time.Time
+	// Only messages logged before Until
+	Until time.Time
+	// Substring of the message content
+	Contains string
+	// Maximum number of messages returned, keeping the most recent ones
+	Limit int
+}
+
+// NewMemoryAppender creates an appender keeping at most maxMessages messages
+// whose formatted size does not exceed maxBytes. A limit of 0 means no limit.
+func NewMemoryAppender(maxMessages int, maxBytes int) *MemoryAppender {
+	return &MemoryAppender{
+		maxMessages: maxMessages,
+		maxBytes:    maxBytes,
+	}
+}
+
+// Append stores the message, dropping the oldest ones if necessary
+func (m *MemoryAppender) Append(msg *Message) {
+	entry := memoryEntry{msg: msg, text: msg.String()}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries = append(m.entries, entry)
+	m.bytes += len(entry.text)
+	for len(m.entries) > 0 &&
+		((m.maxMessages > 0 && len(m.entries) > m.maxMessages) ||
+			(m.maxBytes > 0 && m.bytes > m.maxBytes)) {
+		m.bytes -= len(m.entries[0].text)
+		m.entries[0] = memoryEntry{}
+		m.entries = m.entries[1:]
+	}
+}
+
+func (q *MemoryQuery) matches(entry memoryEntry) bool {
+	msg := entry.msg
+	if !msg.Level.AtLeast(q.MinLevel) {
+		return false
+	}
+	if q.Name != "" {
+		if matched, _ := path.Match(q.Name, msg.Name); !matched {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && msg.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !msg.Time.Before(q.Until) {
+		return false
+	}
+	return q.Contains == "" || strings.Contains(fmt.Sprint(msg.Content), q.Contains)
+}
+
+func (m *MemoryAppender) query(q MemoryQuery) []memoryEntry {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var result []memoryEntry
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+		if q.matches(m.entries[i]) {
+			result = append(result, m.entries[i])
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// Messages returns the stored messages matching the query, from the oldest to the newest
+func (m *MemoryAppender) Messages(q MemoryQuery) []*Message {
+	entries := m.query(q)
+	messages := make([]*Message, len(entries))
+	for i, entry := range entries {
+		messages[i] = entry.msg
+	}
+	return messages
+}
+
+// Len returns the number of stored messages
+func (m *MemoryAppender) Len() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return len(m.entries)
+}
+
+// Clear removes all the stored messages
+func (m *MemoryAppender) Clear() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries = nil
+	m.bytes = 0
+}
+
+// ParseMemoryQuery builds a query from the URL parameters level, name,
+// since, until (RFC 3339 times), q (content substring) and limit
+func ParseMemoryQuery(r *http.Request) (MemoryQuery, error) {
+	params := r.URL.Query()
+	q := MemoryQuery{Name: params.Get("name"), Contains: params.Get("q")}
+	var err error
+	if level := params.Get("level"); level != "" {
+		if q.MinLevel, err = ParseLevel(level); err != nil {
+			return q, err
+		}
+	}
+	if since := params.Get("since"); since != "" {
+		if q.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return q, err
+		}
+	}
+	if until := params.Get("until"); until != "" {
+		if q.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return q, err
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// ServeHTTP serves the messages matching the query built with ParseMemoryQuery.
+// Messages are served as JSON when the format parameter is "json"
+// or the Accept header is "application/json", and as formatted text otherwise.
+func (m *MemoryAppender) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q, err := ParseMemoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries := m.query(q)
+	format := r.URL.Query().Get("format")
+	if format == "json" || (format == "" && strings.Contains(r.Header.Get("Accept"), "application/json")) {
+		messages := make([]JSONMessage, len(entries))
+		for i, entry := range entries {
+			messages[i] = NewJSONMessage(entry.msg)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(messages)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, entry := range entries {
+		_, _ = io.WriteString(w, entry.text)
+	}
+}
@@ -0,0 +1,85 @@
+package loggo
+
+import (
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"text/template"
+	"time"
+)
+
+var _ = Describe("MemoryAppender", func() {
+	var memory *MemoryAppender
+
+	tpl, _ := template.New("foo").Parse("{{.Content}}\n")
+	makeMsg := func(name string, level Level, content string, t time.Time) *Message {
+		return &Message{Name: name, Level: level, Content: content, Time: t, tpl: tpl}
+	}
+
+	contents := func(messages []*Message) []string {
+		result := []string{}
+		for _, msg := range messages {
+			result = append(result, msg.Content.(string))
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		memory = NewMemoryAppender(3, 0)
+		t := dummyTime()
+		memory.Append(makeMsg("db", Debug, "foo", t))
+		memory.Append(makeMsg("db", Error, "bar", t.Add(time.Minute)))
+		memory.Append(makeMsg("http", Info, "baz", t.Add(2*time.Minute)))
+	})
+
+	It("should keep the last messages", func() {
+		memory.Append(makeMsg("http", Info, "qux", dummyTime()))
+		Expect(memory.Len()).To(Equal(3))
+		Expect(contents(memory.Messages(MemoryQuery{}))).To(Equal([]string{"bar", "baz", "qux"}))
+	})
+
+	It("should limit the size", func() {
+		memory = NewMemoryAppender(0, 8)
+		memory.Append(makeMsg("db", Info, "foo", dummyTime()))
+		memory.Append(makeMsg("db", Info, "bar", dummyTime()))
+		memory.Append(makeMsg("db", Info, "baz", dummyTime()))
+		Expect(contents(memory.Messages(MemoryQuery{}))).To(Equal([]string{"bar", "baz"}))
+	})
+
+	It("should query messages", func() {
+		Expect(contents(memory.Messages(MemoryQuery{MinLevel: Info}))).To(Equal([]string{"bar", "baz"}))
+		Expect(contents(memory.Messages(MemoryQuery{Name: "d*"}))).To(Equal([]string{"foo", "bar"}))
+		Expect(contents(memory.Messages(MemoryQuery{Contains: "ba"}))).To(Equal([]string{"bar", "baz"}))
+		Expect(contents(memory.Messages(MemoryQuery{Limit: 1}))).To(Equal([]string{"baz"}))
+		Expect(contents(memory.Messages(MemoryQuery{
+			Since: dummyTime().Add(time.Minute),
+			Until: dummyTime().Add(2 * time.Minute),
+		}))).To(Equal([]string{"bar"}))
+	})
+
+	It("should serve text", func() {
+		w := httptest.NewRecorder()
+		memory.ServeHTTP(w, httptest.NewRequest("GET", "/?level=info&limit=1", nil))
+		Expect(w.Code).To(Equal(200))
+		Expect(w.Body.String()).To(Equal("baz\n"))
+	})
+
+	It("should serve JSON", func() {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/?name=db", nil)
+		r.Header.Set("Accept", "application/json")
+		memory.ServeHTTP(w, r)
+		var messages []map[string]interface{}
+		Expect(json.Unmarshal(w.Body.Bytes(), &messages)).To(BeNil())
+		Expect(messages).To(HaveLen(2))
+		Expect(messages[1]["level"]).To(Equal("ERROR"))
+		Expect(messages[1]["content"]).To(Equal("bar"))
+	})
+
+	It("should reject invalid queries", func() {
+		w := httptest.NewRecorder()
+		memory.ServeHTTP(w, httptest.NewRequest("GET", "/?level=foo", nil))
+		Expect(w.Code).To(Equal(400))
+	})
+})
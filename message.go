@@ -2,6 +2,7 @@ package loggo
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/mgutz/ansi"
 	"strings"
@@ -9,6 +10,14 @@ import (
 	"time"
 )
 
+// Names of the fields holding the OpenTelemetry trace information
+// See the loggo/otel package to populate them
+const (
+	TraceIDField    = "trace_id"
+	SpanIDField     = "span_id"
+	TraceFlagsField = "trace_flags"
+)
+
 // Message is the structure representing a single log message
 type Message struct {
 	// The name of the logger
@@ -17,6 +26,8 @@ type Message struct {
 	Level Level
 	// The content of the log
 	Content interface{}
+	// The format used to build the content with Logf, empty when using Log
+	ContentFormat string
 	// The time of the log
 	Time time.Time
 	// The file of the log call
@@ -24,11 +35,23 @@ type Message struct {
 	// The line number of the log call
 	Line int
 	// The function name of the log call
-	FuncName   string
+	FuncName string
+	// The stack trace of the log call, when enabled for the level
+	Stack string
+	// The errors passed as arguments, each one followed by the errors it wraps
+	Causes []error
+	// The context passed to the log call, nil if none was given
+	Context context.Context
+	// The values extracted from the context by the registered extractors
+	Fields     map[string]interface{}
 	dateFormat string
 	padding    bool
 	color      bool
 	tpl        *template.Template
+	format     *compiledFormat
+	// args holds the arguments until the Lazy ones are evaluated
+	args    []interface{}
+	sprintf bool
 }
 
 // NameUp returns the logger name upper cased
@@ -36,12 +59,48 @@ func (m *Message) NameUp() string {
 	return strings.ToUpper(m.Name)
 }
 
+// Field returns the field with the given name, or nil if it does not exist.
+// It can be used in templates as {{.Field "name"}}
+func (m *Message) Field(name string) interface{} {
+	return m.Fields[name]
+}
+
+// TraceID returns the hex encoded trace ID of the message, if any
+func (m *Message) TraceID() string {
+	return m.stringField(TraceIDField)
+}
+
+// SpanID returns the hex encoded span ID of the message, if any
+func (m *Message) SpanID() string {
+	return m.stringField(SpanIDField)
+}
+
+// TraceFlags returns the hex encoded trace flags of the message, if any
+func (m *Message) TraceFlags() string {
+	return m.stringField(TraceFlagsField)
+}
+
+func (m *Message) stringField(name string) string {
+	str, _ := m.Fields[name].(string)
+	return str
+}
+
+// Package returns the package of the function which logged the message.
+// Caller information must be enabled on the logger.
+func (m *Message) Package() string {
+	slash := strings.LastIndex(m.FuncName, "/")
+	if dot := strings.Index(m.FuncName[slash+1:], "."); dot >= 0 {
+		return m.FuncName[:slash+1+dot]
+	}
+	return m.FuncName
+}
+
 // LevelStr formats the log level
 func (m *Message) LevelStr() string {
 	str := m.Level.String()
 	if m.padding {
-		for len(str) < 7 {
-			str += " "
+		if width := levelNameWidth(); len(str) < width {
+			str += strings.Repeat(" ", width-len(str))
 		}
 	}
 	return str
@@ -49,19 +108,82 @@ func (m *Message) LevelStr() string {
 
 // String returns a formatted representation of the message
 func (m *Message) String() string {
-	buffer := bytes.NewBufferString("")
-	err := m.tpl.Execute(buffer, m)
-	if err != nil {
-		return fmt.Sprintf("%s\n", m.Content)
+	buf := getBuffer()
+	*buf = m.appendTo(*buf)
+	str := string(*buf)
+	putBuffer(buf)
+	return str
+}
+
+// appendTo appends the formatted and eventually colored message to buf
+func (m *Message) appendTo(buf []byte) []byte {
+	if !m.color {
+		return m.appendFormatted(buf)
+	}
+	start := len(buf)
+	buf = m.appendFormatted(buf)
+	colored := ansi.Color(string(buf[start:]), levelColor(m.Level))
+	return append(buf[:start], colored...)
+}
+
+func (m *Message) appendFormatted(buf []byte) []byte {
+	if m.format != nil {
+		return m.format.appendTo(buf, m)
 	}
-	str := buffer.String()
-	if m.color {
-		str = ansi.Color(str, Colors[m.Level])
+	start := len(buf)
+	buffer := bytes.NewBuffer(buf)
+	if err := m.tpl.Execute(buffer, m); err != nil {
+		return fmt.Appendf(buf[:start], "%s\n", m.Content)
 	}
-	return str
+	return buffer.Bytes()
 }
 
 // TimeStr formats the time
 func (m *Message) TimeStr() string {
 	return m.Time.Format(m.dateFormat)
 }
+
+// JSONMessage is the JSON representation of a message used by
+// the memory appender and the appenders sending messages as JSON
+type JSONMessage struct {
+	Name     string                 `json:"name"`
+	Level    string                 `json:"level"`
+	Time     time.Time              `json:"time"`
+	Content  string                 `json:"content"`
+	File     string                 `json:"file,omitempty"`
+	Line     int                    `json:"line,omitempty"`
+	FuncName string                 `json:"func,omitempty"`
+	Stack    string                 `json:"stack,omitempty"`
+	Causes   []string               `json:"causes,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+}
+
+// NewJSONMessage returns the JSON representation of msg.
+// The fields which are not strings, booleans or numbers are formatted with fmt.Sprint
+func NewJSONMessage(msg *Message) JSONMessage {
+	jsonMsg := JSONMessage{
+		Name:     msg.Name,
+		Level:    msg.Level.String(),
+		Time:     msg.Time,
+		Content:  fmt.Sprint(msg.Content),
+		File:     msg.File,
+		Line:     msg.Line,
+		FuncName: msg.FuncName,
+		Stack:    msg.Stack,
+	}
+	for _, cause := range msg.Causes {
+		jsonMsg.Causes = append(jsonMsg.Causes, cause.Error())
+	}
+	if len(msg.Fields) > 0 {
+		jsonMsg.Fields = make(map[string]interface{}, len(msg.Fields))
+		for k, v := range msg.Fields {
+			switch v.(type) {
+			case string, bool, int, int32, int64, uint, uint32, uint64, float32, float64, nil:
+				jsonMsg.Fields[k] = v
+			default:
+				jsonMsg.Fields[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	return jsonMsg
+}
@@ -0,0 +1,34 @@
+// Package loggo_otel correlates loggo messages with OpenTelemetry traces
+package loggo_otel
+
+import (
+	"context"
+	"github.com/claudetech/loggo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Register registers context extractors storing the trace ID, span ID
+// and trace flags of the active span in the message fields.
+// The values are then available in templates as {{.TraceID}},
+// {{.SpanID}} and {{.TraceFlags}}
+func Register() {
+	loggo.RegisterContextExtractor(loggo.TraceIDField, func(ctx context.Context) (interface{}, bool) {
+		sc := trace.SpanContextFromContext(ctx)
+		return sc.TraceID().String(), sc.HasTraceID()
+	})
+	loggo.RegisterContextExtractor(loggo.SpanIDField, func(ctx context.Context) (interface{}, bool) {
+		sc := trace.SpanContextFromContext(ctx)
+		return sc.SpanID().String(), sc.HasSpanID()
+	})
+	loggo.RegisterContextExtractor(loggo.TraceFlagsField, func(ctx context.Context) (interface{}, bool) {
+		sc := trace.SpanContextFromContext(ctx)
+		return sc.TraceFlags().String(), sc.IsValid()
+	})
+}
+
+// Unregister removes the extractors registered by Register
+func Unregister() {
+	loggo.UnregisterContextExtractor(loggo.TraceIDField)
+	loggo.UnregisterContextExtractor(loggo.SpanIDField)
+	loggo.UnregisterContextExtractor(loggo.TraceFlagsField)
+}
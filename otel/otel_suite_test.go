@@ -0,0 +1,13 @@
+package loggo_otel
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOtel(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Otel Suite")
+}
@@ -0,0 +1,51 @@
+package loggo_otel
+
+import (
+	"context"
+	"github.com/claudetech/loggo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type stringAppender struct {
+	str string
+}
+
+func (s *stringAppender) Append(msg *loggo.Message) {
+	s.str += msg.String()
+}
+
+var _ = Describe("Otel", func() {
+	var logger *loggo.Logger
+	var appender *stringAppender
+
+	BeforeEach(func() {
+		Register()
+		logger = loggo.New("otel")
+		appender = &stringAppender{}
+		logger.AddAppender(appender, loggo.EmptyFlag)
+		logger.SetFormat("{{.TraceID}} {{.SpanID}} {{.TraceFlags}}")
+	})
+
+	AfterEach(func() {
+		Unregister()
+		logger.Destroy()
+	})
+
+	It("should add the span context to messages", func() {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{0x01, 0x02},
+			SpanID:     trace.SpanID{0x03},
+			TraceFlags: trace.FlagsSampled,
+		})
+		ctx := trace.ContextWithSpanContext(context.Background(), sc)
+		logger.InfoCtx(ctx, "foo")
+		Expect(appender.str).To(Equal("01020000000000000000000000000000 0300000000000000 01\n"))
+	})
+
+	It("should leave the fields empty without span", func() {
+		logger.InfoCtx(context.Background(), "foo")
+		Expect(appender.str).To(Equal("  \n"))
+	})
+})
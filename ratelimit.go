@@ -0,0 +1,314 @@
+package loggo
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Flags to choose how the rate limited appender groups messages
+const (
+	RateLimitPerLevel = 1 << iota
+	RateLimitPerName  = 1 << iota
+)
+
+const (
+	rateLimitSummaryFormat = "%d messages suppressed by rate limiting"
+	// idle buckets are evicted at most once per interval
+	rateLimitEvictInterval = time.Minute
+)
+
+type rateLimitKey struct {
+	level Level
+	name  string
+}
+
+type tokenBucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+	lastMsg    *Message
+	maxLevel   Level
+	timer      *time.Timer
+}
+
+// rateLimiter holds the token buckets shared by the rate limited
+// appender and filter. It is not safe for concurrent use
+type rateLimiter struct {
+	rate      float64
+	burst     float64
+	flags     int
+	buckets   map[rateLimitKey]*tokenBucket
+	lastEvict time.Time
+}
+
+func newRateLimiter(rate float64, burst int, flags int) rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		flags:   flags,
+		buckets: make(map[rateLimitKey]*tokenBucket),
+	}
+}
+
+func (r *rateLimiter) bucket(msg *Message, now time.Time) *tokenBucket {
+	key := rateLimitKey{}
+	if r.flags&RateLimitPerLevel != 0 {
+		key.level = msg.Level
+	}
+	if r.flags&RateLimitPerName != 0 {
+		key.name = msg.Name
+	}
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: r.burst, last: now}
+		r.buckets[key] = b
+	}
+	return b
+}
+
+func (r *rateLimiter) refill(b *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * r.rate
+		if b.tokens > r.burst {
+			b.tokens = r.burst
+		}
+	}
+	b.last = now
+}
+
+// take refills the bucket of the message and takes a token from it,
+// returning the bucket and whether a token was available
+func (r *rateLimiter) take(msg *Message, now time.Time) (*tokenBucket, bool) {
+	r.evict(now)
+	b := r.bucket(msg, now)
+	r.refill(b, now)
+	if b.tokens < 1 {
+		return b, false
+	}
+	b.tokens--
+	return b, true
+}
+
+// wait returns the time until the bucket has a token again
+func (r *rateLimiter) wait(b *tokenBucket) time.Duration {
+	return time.Duration((1 - b.tokens) / r.rate * float64(time.Second))
+}
+
+// evict removes the buckets which are full again and have nothing
+// left to report, as they are equivalent to new buckets
+func (r *rateLimiter) evict(now time.Time) {
+	if now.Sub(r.lastEvict) < rateLimitEvictInterval {
+		return
+	}
+	r.lastEvict = now
+	for key, b := range r.buckets {
+		r.refill(b, now)
+		if b.tokens >= r.burst && b.suppressed == 0 && b.timer == nil {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// RateLimitedAppender wraps an appender with a token bucket.
+// Messages received when the bucket is empty are dropped, and
+// a single message counting them is sent once capacity returns.
+type RateLimitedAppender struct {
+	rateLimiter
+	appender Appender
+	nowFunc  func() time.Time
+	lock     sync.Mutex
+}
+
+// NewRateLimitedAppender returns an appender letting through at most
+// rate messages per second to the given appender, with bursts up to burst messages.
+// By default a single bucket is used for all messages, flags can be
+// RateLimitPerLevel and/or RateLimitPerName to use a bucket per level and/or logger name.
+func NewRateLimitedAppender(appender Appender, rate float64, burst int, flags int) *RateLimitedAppender {
+	return &RateLimitedAppender{
+		rateLimiter: newRateLimiter(rate, burst, flags),
+		appender:    appender,
+		nowFunc:     time.Now,
+	}
+}
+
+// Append forwards the message if the bucket has enough tokens
+func (r *RateLimitedAppender) Append(msg *Message) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := r.nowFunc()
+	b, ok := r.take(msg, now)
+	if !ok {
+		r.suppress(b, msg)
+		return
+	}
+	r.flushSummary(b, now)
+	r.appender.Append(msg)
+}
+
+func (r *RateLimitedAppender) suppress(b *tokenBucket, msg *Message) {
+	if b.suppressed == 0 || !b.maxLevel.AtLeast(msg.Level) {
+		b.maxLevel = msg.Level
+	}
+	b.suppressed++
+	b.lastMsg = msg
+	if b.timer == nil && r.rate > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(r.wait(b), func() {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			// the timer may have been replaced after being stopped too late
+			if b.timer != timer {
+				return
+			}
+			b.timer = nil
+			r.flushSummary(b, r.nowFunc())
+		})
+		b.timer = timer
+	}
+}
+
+// flushSummary sends the message counting the suppressed messages, if any
+func (r *RateLimitedAppender) flushSummary(b *tokenBucket, now time.Time) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if b.suppressed == 0 {
+		return
+	}
+	summary := *b.lastMsg
+	summary.Level = b.maxLevel
+	summary.Time = now
+	summary.ContentFormat = rateLimitSummaryFormat
+	summary.Content = fmt.Sprintf(rateLimitSummaryFormat, b.suppressed)
+	b.suppressed = 0
+	b.lastMsg = nil
+	r.appender.Append(&summary)
+}
+
+// Suppressed returns the number of messages currently waiting to be reported
+func (r *RateLimitedAppender) Suppressed() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	total := 0
+	for _, b := range r.buckets {
+		total += b.suppressed
+	}
+	return total
+}
+
+// Close sends the pending summaries and closes the wrapped appender
+// if it implements io.Closer
+func (r *RateLimitedAppender) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := r.nowFunc()
+	for _, b := range r.buckets {
+		r.flushSummary(b, now)
+	}
+	if closer, ok := r.appender.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// RateLimitFilter is a filter letting through at most rate messages
+// per second with bursts up to burst messages, using the same buckets as
+// RateLimitedAppender. With EnableSummary, the number of dropped messages
+// is logged once capacity returns.
+type RateLimitFilter struct {
+	rateLimiter
+	nowFunc       func() time.Time
+	total         uint64
+	summaryLogger *Logger
+	lock          sync.Mutex
+}
+
+// NewRateLimitFilter returns a filter letting through at most rate messages
+// per second, with bursts up to burst messages. flags are the same as for NewRateLimitedAppender
+func NewRateLimitFilter(rate float64, burst int, flags int) *RateLimitFilter {
+	return &RateLimitFilter{
+		rateLimiter: newRateLimiter(rate, burst, flags),
+		nowFunc:     time.Now,
+	}
+}
+
+// ShouldLog returns true if the bucket of the message has enough tokens
+func (f *RateLimitFilter) ShouldLog(msg *Message) bool {
+	if msg.ContentFormat == rateLimitSummaryFormat {
+		return true
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	b, ok := f.take(msg, f.nowFunc())
+	if ok {
+		return true
+	}
+	f.total++
+	if f.summaryLogger != nil {
+		f.suppress(b, msg)
+	}
+	return false
+}
+
+func (f *RateLimitFilter) suppress(b *tokenBucket, msg *Message) {
+	if b.suppressed == 0 || !b.maxLevel.AtLeast(msg.Level) {
+		b.maxLevel = msg.Level
+	}
+	b.suppressed++
+	if b.timer != nil || f.rate <= 0 {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(f.wait(b), func() {
+		f.lock.Lock()
+		if b.timer != timer {
+			f.lock.Unlock()
+			return
+		}
+		b.timer = nil
+		count, level, logger := b.suppressed, b.maxLevel, f.summaryLogger
+		b.suppressed = 0
+		f.lock.Unlock()
+		// logged without the lock, as the summary goes through ShouldLog
+		if logger != nil && count > 0 {
+			logger.Logf(level, rateLimitSummaryFormat, count)
+		}
+	})
+	b.timer = timer
+}
+
+// Suppressed returns the total number of messages dropped by the filter
+func (f *RateLimitFilter) Suppressed() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.total
+}
+
+// EnableSummary logs to the given logger how many messages have been dropped,
+// once capacity returns. The summary has the highest level of the dropped messages
+// and is never rate limited.
+func (f *RateLimitFilter) EnableSummary(logger *Logger) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.summaryLogger = logger
+}
+
+// Stop stops logging summaries and the pending summary timers
+func (f *RateLimitFilter) Stop() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.summaryLogger = nil
+	for _, b := range f.buckets {
+		if b.timer != nil {
+			b.timer.Stop()
+			b.timer = nil
+		}
+		b.suppressed = 0
+	}
+}
@@ -0,0 +1,140 @@
+package loggo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"text/template"
+	"time"
+)
+
+var _ = Describe("RateLimitedAppender", func() {
+	var appender *dummyAppender
+	var limited *RateLimitedAppender
+	var now time.Time
+
+	tpl, _ := template.New("foo").Parse("{{.Name}} {{.Level}} {{.Content}}\n")
+	makeMsg := func(name string, level Level, content string) *Message {
+		return &Message{Name: name, Level: level, Content: content, tpl: tpl}
+	}
+
+	BeforeEach(func() {
+		now = dummyTime()
+		appender = &dummyAppender{}
+		limited = NewRateLimitedAppender(appender, 1, 2, 0)
+		limited.nowFunc = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		// stop the pending timers before the next test resets now
+		limited.Close()
+	})
+
+	It("should let through bursts", func() {
+		limited.Append(makeMsg("a", Info, "1"))
+		limited.Append(makeMsg("a", Info, "2"))
+		limited.Append(makeMsg("a", Info, "3"))
+		Expect(appender.str).To(Equal("a INFO 1\na INFO 2\n"))
+		Expect(limited.Suppressed()).To(Equal(1))
+	})
+
+	It("should report suppressed messages once capacity returns", func() {
+		for i := 0; i < 5; i++ {
+			limited.Append(makeMsg("a", Info, "foo"))
+		}
+		limited.Append(makeMsg("a", Error, "bar"))
+		appender.str = ""
+		now = now.Add(time.Second)
+		limited.Append(makeMsg("a", Info, "baz"))
+		Expect(appender.str).To(Equal("a ERROR 4 messages suppressed by rate limiting\na INFO baz\n"))
+		Expect(limited.Suppressed()).To(Equal(0))
+	})
+
+	It("should report suppressed messages without new messages", func() {
+		limited = NewRateLimitedAppender(appender, 100, 1, 0)
+		limited.Append(makeMsg("a", Info, "1"))
+		limited.Append(makeMsg("a", Info, "2"))
+		Eventually(limited.Suppressed).Should(Equal(0))
+		limited.lock.Lock()
+		defer limited.lock.Unlock()
+		Expect(appender.str).To(Equal("a INFO 1\na INFO 1 messages suppressed by rate limiting\n"))
+	})
+
+	It("should use a bucket per level", func() {
+		limited.flags = RateLimitPerLevel
+		for i := 0; i < 3; i++ {
+			limited.Append(makeMsg("a", Info, "foo"))
+			limited.Append(makeMsg("a", Error, "foo"))
+		}
+		Expect(limited.Suppressed()).To(Equal(2))
+	})
+
+	It("should use a bucket per name", func() {
+		limited.flags = RateLimitPerName
+		for i := 0; i < 3; i++ {
+			limited.Append(makeMsg("a", Info, "foo"))
+			limited.Append(makeMsg("b", Info, "foo"))
+		}
+		Expect(limited.Suppressed()).To(Equal(2))
+	})
+
+	It("should evict idle buckets", func() {
+		limited.flags = RateLimitPerName
+		limited.Append(makeMsg("a", Info, "foo"))
+		limited.Append(makeMsg("b", Info, "foo"))
+		Expect(limited.buckets).To(HaveLen(2))
+		now = now.Add(time.Minute)
+		limited.Append(makeMsg("c", Info, "foo"))
+		Expect(limited.buckets).To(HaveLen(1))
+	})
+
+	It("should report suppressed messages on close", func() {
+		for i := 0; i < 3; i++ {
+			limited.Append(makeMsg("a", Info, "foo"))
+		}
+		Expect(limited.Close()).To(BeNil())
+		Expect(appender.str).To(HaveSuffix("a INFO 1 messages suppressed by rate limiting\n"))
+	})
+})
+
+var _ = Describe("RateLimitFilter", func() {
+	var filter *RateLimitFilter
+	var now time.Time
+
+	BeforeEach(func() {
+		now = dummyTime()
+		filter = NewRateLimitFilter(1, 2, RateLimitPerLevel)
+		filter.nowFunc = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		filter.Stop()
+	})
+
+	It("should let through bursts and refill", func() {
+		msg := &Message{Level: Info, Content: "foo"}
+		Expect(filter.ShouldLog(msg)).To(BeTrue())
+		Expect(filter.ShouldLog(msg)).To(BeTrue())
+		Expect(filter.ShouldLog(msg)).To(BeFalse())
+		Expect(filter.ShouldLog(&Message{Level: Error, Content: "foo"})).To(BeTrue())
+		now = now.Add(time.Second)
+		Expect(filter.ShouldLog(msg)).To(BeTrue())
+		Expect(filter.ShouldLog(msg)).To(BeFalse())
+		Expect(filter.Suppressed()).To(Equal(uint64(2)))
+	})
+
+	It("should log a summary once capacity returns", func() {
+		logger := New("ratelimit")
+		defer logger.Destroy()
+		appender := NewMemoryAppender(0, 0)
+		filter = NewRateLimitFilter(100, 1, 0)
+		filter.EnableSummary(logger)
+		logger.AddAppenderWithFilter(appender, filter, EmptyFlag)
+		logger.Info("foo")
+		logger.Info("bar")
+		logger.Error("baz")
+		Eventually(appender.Len).Should(Equal(2))
+		summary := appender.Messages(MemoryQuery{})[1]
+		Expect(summary.Level).To(Equal(Error))
+		Expect(summary.Content).To(Equal("2 messages suppressed by rate limiting"))
+	})
+})
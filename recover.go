@@ -0,0 +1,67 @@
+package loggo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover recovers from a panic and logs it with Fatal level,
+// including the panic value and the stack trace, then flushes the appenders.
+// It must be called directly by defer:
+//
+//	defer logger.Recover()
+func (l *Logger) Recover() {
+	if value := recover(); value != nil {
+		l.logPanic(nil, value, "panic: ")
+	}
+}
+
+// RecoverAndRepanic works like Recover but panics again
+// with the same value once the panic has been logged
+func (l *Logger) RecoverAndRepanic() {
+	if value := recover(); value != nil {
+		l.logPanic(nil, value, "panic: ")
+		panic(value)
+	}
+}
+
+// RecoveryHandler returns a handler recovering from the panics of next.
+// Panics are logged with Fatal level, including the request method, URL,
+// remote address and user agent, and a 500 status is sent to the client.
+// When repanic is true, the panic is propagated once logged.
+// http.ErrAbortHandler panics are propagated without being logged.
+func (l *Logger) RecoveryHandler(next http.Handler, repanic bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+			prefix := fmt.Sprintf("panic serving %s %s for %s (%s): ",
+				r.Method, r.URL.RequestURI(), r.RemoteAddr, r.UserAgent())
+			l.logPanic(r.Context(), value, prefix)
+			if repanic {
+				panic(value)
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logPanic logs the panic, which is reported as coming from the function which panicked.
+// It must be called by the deferred function which recovered
+func (l *Logger) logPanic(ctx context.Context, value interface{}, prefix string) {
+	if l.Enabled(Fatal) {
+		// skip logPanic and the deferred function, then the runtime panic frames
+		msg := l.makeMessage(ctx, Fatal, "", prefix+fmt.Sprint(value), []interface{}{value}, 2)
+		msg.Stack = string(debug.Stack())
+		l.outputLog(msg)
+	}
+	_ = l.Flush()
+}
@@ -0,0 +1,129 @@
+package loggo
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+type flushingAppender struct {
+	dummyAppender
+	flushed bool
+	lock    sync.Mutex
+}
+
+func (f *flushingAppender) Append(msg *Message) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.dummyAppender.Append(msg)
+}
+
+func (f *flushingAppender) Flush() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.flushed = true
+	return nil
+}
+
+var _ = Describe("Recover", func() {
+	var logger *Logger
+	var appender *flushingAppender
+
+	BeforeEach(func() {
+		logger = New("recover")
+		appender = &flushingAppender{}
+		logger.AddAppender(appender, Async)
+		logger.SetFormat("{{.LevelStr}} {{.Content}}")
+		logger.DisablePadding()
+	})
+
+	AfterEach(func() {
+		logger.Destroy()
+	})
+
+	It("should log and flush panics", func() {
+		func() {
+			defer logger.Recover()
+			panic("foo")
+		}()
+		Expect(appender.str).To(Equal("FATAL panic: foo\n"))
+		Expect(appender.flushed).To(BeTrue())
+	})
+
+	It("should capture the stack and error causes", func() {
+		var msg *Message
+		logger.AddAppenderWithFilter(appender, FilterFunc(func(m *Message) bool {
+			msg = m
+			return false
+		}), 0)
+		err := errors.New("foo")
+		func() {
+			defer logger.Recover()
+			panic(err)
+		}()
+		Expect(msg.Stack).To(ContainSubstring("recover_test.go"))
+		Expect(msg.Causes).To(Equal([]error{err}))
+	})
+
+	It("should report the line which panicked", func() {
+		var msg *Message
+		logger.EnableCallerInfo()
+		logger.AddAppenderWithFilter(appender, FilterFunc(func(m *Message) bool {
+			msg = m
+			return false
+		}), 0)
+		var line int
+		func() {
+			defer logger.Recover()
+			_, _, line, _ = runtime.Caller(0)
+			panic("foo")
+		}()
+		Expect(filepath.Base(msg.File)).To(Equal("recover_test.go"))
+		Expect(msg.Line).To(Equal(line + 1))
+	})
+
+	It("should not log disabled panics", func() {
+		logger.SetLevel(Level(Fatal + 1))
+		func() {
+			defer logger.Recover()
+			panic("foo")
+		}()
+		Expect(appender.str).To(BeEmpty())
+		Expect(appender.flushed).To(BeTrue())
+	})
+
+	It("should repanic", func() {
+		Expect(func() {
+			defer logger.RecoverAndRepanic()
+			panic("foo")
+		}).To(PanicWith("foo"))
+		Expect(appender.str).To(Equal("FATAL panic: foo\n"))
+	})
+
+	It("should recover HTTP handlers", func() {
+		handler := logger.RecoveryHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("foo")
+		}), false)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/bar?baz=%25d", nil)
+		r.Header.Set("User-Agent", "test")
+		handler.ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		Expect(appender.str).To(Equal("FATAL panic serving GET /bar?baz=%25d for 192.0.2.1:1234 (test): foo\n"))
+	})
+
+	It("should propagate aborted handlers", func() {
+		handler := logger.RecoveryHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}), false)
+		Expect(func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}).To(PanicWith(http.ErrAbortHandler))
+		Expect(appender.str).To(BeEmpty())
+	})
+})
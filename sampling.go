@@ -0,0 +1,179 @@
+package loggo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	samplingSummaryFormat   = "sampling suppressed %d messages in the last %s: %s"
+	defaultSamplingInterval = time.Second
+)
+
+type samplingKey struct {
+	level   Level
+	content string
+}
+
+type samplingCounter struct {
+	start      time.Time
+	count      uint64
+	suppressed uint64
+}
+
+// SamplingFilter caps high-volume messages.
+// For each level and content format, the first First messages of each
+// Interval are logged, then only every Thereafter-th message.
+// When Thereafter is 0, all the messages after the first First are dropped.
+type SamplingFilter struct {
+	first      uint64
+	thereafter uint64
+	interval   time.Duration
+	nowFunc    func() time.Time
+	counters   map[samplingKey]*samplingCounter
+	suppressed uint64
+	lastPrune  time.Time
+	done       chan struct{}
+	lock       sync.Mutex
+}
+
+// NewSamplingFilter creates a new sampling filter.
+// interval defaults to 1s when it is not positive
+func NewSamplingFilter(first, thereafter int, interval time.Duration) *SamplingFilter {
+	if interval <= 0 {
+		interval = defaultSamplingInterval
+	}
+	return &SamplingFilter{
+		first:      uint64(first),
+		thereafter: uint64(thereafter),
+		interval:   interval,
+		nowFunc:    time.Now,
+		counters:   make(map[samplingKey]*samplingCounter),
+	}
+}
+
+func makeSamplingKey(msg *Message) samplingKey {
+	content := msg.ContentFormat
+	if content == "" {
+		msg.ResolveContent()
+		content = fmt.Sprint(msg.Content)
+	}
+	return samplingKey{level: msg.Level, content: content}
+}
+
+// ShouldLog returns true if the message is part of the sample
+func (f *SamplingFilter) ShouldLog(msg *Message) bool {
+	if msg.ContentFormat == samplingSummaryFormat {
+		return true
+	}
+	key := makeSamplingKey(msg)
+	now := f.nowFunc()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.prune(now)
+	counter, ok := f.counters[key]
+	if !ok {
+		counter = &samplingCounter{start: now}
+		f.counters[key] = counter
+	} else if now.Sub(counter.start) >= f.interval {
+		counter.start = now
+		counter.count = 0
+	}
+	counter.count++
+	if counter.count <= f.first {
+		return true
+	}
+	if f.thereafter > 0 && (counter.count-f.first)%f.thereafter == 0 {
+		return true
+	}
+	counter.suppressed++
+	f.suppressed++
+	return false
+}
+
+// prune removes the counters which have not been used for an interval
+// and have no suppressed messages left to report. Without summary,
+// suppressed messages are never reported so they do not keep counters alive
+func (f *SamplingFilter) prune(now time.Time) {
+	if now.Sub(f.lastPrune) < f.interval {
+		return
+	}
+	f.lastPrune = now
+	for key, counter := range f.counters {
+		if (counter.suppressed == 0 || f.done == nil) && now.Sub(counter.start) >= f.interval {
+			delete(f.counters, key)
+		}
+	}
+}
+
+// Suppressed returns the total number of messages dropped by the filter
+func (f *SamplingFilter) Suppressed() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.suppressed
+}
+
+// summary returns the number of messages suppressed since the
+// last summary and a description of the sampled messages
+func (f *SamplingFilter) summary() (uint64, string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var total uint64
+	var details []string
+	for key, counter := range f.counters {
+		if counter.suppressed == 0 {
+			continue
+		}
+		total += counter.suppressed
+		details = append(details, fmt.Sprintf("%s %q: %d", key.level, key.content, counter.suppressed))
+		counter.suppressed = 0
+	}
+	sort.Strings(details)
+	return total, strings.Join(details, ", ")
+}
+
+// EnableSummary logs a summary of the suppressed messages to the
+// given logger every interval, when some messages have been suppressed.
+// Summary messages are never sampled.
+func (f *SamplingFilter) EnableSummary(logger *Logger, level Level) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.done != nil {
+		return
+	}
+	f.done = make(chan struct{})
+	go f.summaryLoop(logger, level, f.done)
+}
+
+func (f *SamplingFilter) summaryLoop(logger *Logger, level Level, done chan struct{}) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.logSummary(logger, level)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (f *SamplingFilter) logSummary(logger *Logger, level Level) {
+	if count, details := f.summary(); count > 0 {
+		logger.Logf(level, samplingSummaryFormat, count, f.interval, details)
+	}
+}
+
+// Stop stops logging summaries
+func (f *SamplingFilter) Stop() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.done != nil {
+		close(f.done)
+		f.done = nil
+	}
+}
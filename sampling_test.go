@@ -0,0 +1,89 @@
+package loggo
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("SamplingFilter", func() {
+	var filter *SamplingFilter
+	var now time.Time
+
+	sample := func(msg *Message, n int) (logged int) {
+		for i := 0; i < n; i++ {
+			if filter.ShouldLog(msg) {
+				logged++
+			}
+		}
+		return
+	}
+
+	BeforeEach(func() {
+		now = dummyTime()
+		filter = NewSamplingFilter(3, 10, time.Second)
+		filter.nowFunc = func() time.Time { return now }
+	})
+
+	It("should log the first messages then every Mth", func() {
+		msg := &Message{Level: Debug, Content: "foo"}
+		Expect(sample(msg, 3)).To(Equal(3))
+		Expect(sample(msg, 9)).To(Equal(0))
+		Expect(sample(msg, 1)).To(Equal(1))
+		Expect(sample(msg, 20)).To(Equal(2))
+		Expect(filter.Suppressed()).To(Equal(uint64(27)))
+	})
+
+	It("should reset every interval", func() {
+		msg := &Message{Level: Debug, Content: "foo"}
+		Expect(sample(msg, 5)).To(Equal(3))
+		now = now.Add(time.Second)
+		Expect(sample(msg, 5)).To(Equal(3))
+	})
+
+	It("should sample by level and content format", func() {
+		Expect(sample(&Message{Level: Debug, Content: "a 1", ContentFormat: "a %d"}, 2)).To(Equal(2))
+		Expect(sample(&Message{Level: Debug, Content: "a 2", ContentFormat: "a %d"}, 2)).To(Equal(1))
+		Expect(sample(&Message{Level: Info, Content: "a 2", ContentFormat: "a %d"}, 2)).To(Equal(2))
+		Expect(sample(&Message{Level: Debug, Content: "b"}, 2)).To(Equal(2))
+	})
+
+	It("should drop everything after the first messages without thereafter", func() {
+		filter.thereafter = 0
+		Expect(sample(&Message{Level: Debug, Content: "foo"}, 100)).To(Equal(3))
+	})
+
+	It("should prune idle counters without summary", func() {
+		sample(&Message{Level: Debug, Content: "foo"}, 5)
+		sample(&Message{Level: Debug, Content: "bar"}, 5)
+		Expect(filter.counters).To(HaveLen(2))
+		now = now.Add(time.Second)
+		sample(&Message{Level: Debug, Content: "baz"}, 1)
+		Expect(filter.counters).To(HaveLen(1))
+		Expect(filter.Suppressed()).To(Equal(uint64(4)))
+	})
+
+	It("should default the interval when it is not positive", func() {
+		filter = NewSamplingFilter(1, 0, 0)
+		Expect(filter.interval).To(Equal(time.Second))
+		filter.EnableSummary(New("sampling"), Info)
+		filter.Stop()
+	})
+
+	It("should log a summary", func() {
+		logger := New("sampling")
+		defer logger.Destroy()
+		appender := &dummyAppender{}
+		logger.SetFormat("{{.LevelStr}} {{.Content}}")
+		logger.AddAppenderWithFilter(appender, filter, 0)
+		for i := 0; i < 5; i++ {
+			logger.Debugf("foo %d", i)
+		}
+		appender.str = ""
+		filter.logSummary(logger, Info)
+		Expect(appender.str).To(Equal("INFO    sampling suppressed 2 messages in the last 1s: DEBUG \"foo %d\": 2\n"))
+		appender.str = ""
+		filter.logSummary(logger, Info)
+		Expect(appender.str).To(BeEmpty())
+	})
+})
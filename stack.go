@@ -0,0 +1,45 @@
+package loggo
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+)
+
+// formatStack formats the frames like the stack traces printed on panic
+func formatStack(frames []runtime.Frame) string {
+	buffer := bytes.NewBufferString("")
+	for _, frame := range frames {
+		fmt.Fprintf(buffer, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return buffer.String()
+}
+
+// errorCauses returns the errors contained in args,
+// each one followed by the errors it wraps
+func errorCauses(args []interface{}) []error {
+	var causes []error
+	for _, arg := range args {
+		if err, ok := arg.(error); ok && err != nil {
+			causes = appendCauses(causes, err)
+		}
+	}
+	return causes
+}
+
+func appendCauses(causes []error, err error) []error {
+	causes = append(causes, err)
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if inner != nil {
+				causes = appendCauses(causes, inner)
+			}
+		}
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			causes = appendCauses(causes, inner)
+		}
+	}
+	return causes
+}